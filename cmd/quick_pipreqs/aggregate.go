@@ -0,0 +1,38 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"path/filepath"
+
+	"github.com/bevelwork/quick_pipreqs/pipreqs"
+)
+
+// writeAggregate merges the requirements files of s into one file at path
+// (see pipreqs.Aggregate) and returns the conflicting pins. A dry run
+// writes nothing, but merges what the files would become. path may not be
+// one of the files the run processed.
+func writeAggregate(logger *slog.Logger, s pipreqs.Summary, path string, dryRun bool) ([]pipreqs.Conflict, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range s.Results {
+		if p, _ := filepath.Abs(r.Path()); p == abs {
+			return nil, fmt.Errorf("%s is one of the processed requirements files", path)
+		}
+	}
+	content, conflicts, err := pipreqs.Aggregate(s)
+	if err != nil {
+		return nil, err
+	}
+	if dryRun {
+		logger.Info("would write aggregate requirements", "path", path, "bytes", len(content))
+		return conflicts, nil
+	}
+	if err := pipreqs.WriteFileAtomic(path, content, 0o644); err != nil {
+		return nil, err
+	}
+	logger.Info("wrote aggregate requirements", "path", path)
+	return conflicts, nil
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+
+	"github.com/bevelwork/quick_pipreqs/pipreqs"
+)
+
+// runClean implements the clean subcommand: it deletes leftover backup files
+// (or only lists them in dry-run mode) and returns the process exit code.
+func runClean(logger *slog.Logger, root string, opts pipreqs.DiscoverOptions, suffix string, dryRun bool) int {
+	backups, err := pipreqs.FindBackups(root, opts, suffix)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "error:", err)
+		return exitFailure
+	}
+	logger.Info("found backup files", "count", len(backups))
+
+	removed, failed := 0, 0
+	for _, b := range backups {
+		if dryRun {
+			fmt.Println("would remove:", b)
+			removed++
+			continue
+		}
+		if err := os.Remove(b); err != nil {
+			fmt.Fprintln(os.Stderr, "error:", err)
+			failed++
+			continue
+		}
+		logger.Debug("removed", "path", b)
+		removed++
+	}
+
+	label := "removed:"
+	if dryRun {
+		label = "would remove:"
+	}
+	fmt.Println("found:", len(backups), label, removed, "errors:", failed)
+	if failed > 0 {
+		return exitFailure
+	}
+	return 0
+}
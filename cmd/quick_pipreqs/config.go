@@ -0,0 +1,170 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// configFileName is the config file looked for in the target path and the
+// working directory when --config is not given.
+const configFileName = ".quick_pipreqs.yaml"
+
+// configEntry is one key of a config file with its value(s).
+type configEntry struct {
+	key    string
+	values []string
+	line   int
+}
+
+// findConfig returns the first of dirs containing configFileName, or "" if
+// none does.
+func findConfig(dirs ...string) string {
+	for _, dir := range dirs {
+		p := filepath.Join(dir, configFileName)
+		if info, err := os.Stat(p); err == nil && !info.IsDir() {
+			return p
+		}
+	}
+	return ""
+}
+
+// parseConfig reads the YAML subset used by config files: top-level
+// "key: value" pairs, where a value is a scalar (optionally quoted), a
+// flow list "[a, b]", or a block list of "- item" lines below the key.
+// Comments start with "#".
+func parseConfig(data []byte) ([]configEntry, error) {
+	var entries []configEntry
+	open := -1 // index of the key awaiting "- item" lines
+	for i, raw := range strings.Split(string(data), "\n") {
+		n := i + 1
+		line := strings.TrimRight(stripComment(raw), " \t\r")
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		trimmed := strings.TrimSpace(line)
+		if item, ok := strings.CutPrefix(trimmed, "- "); ok || trimmed == "-" {
+			if open < 0 || line == trimmed {
+				return nil, fmt.Errorf("line %d: list item outside a key", n)
+			}
+			v, err := unquote(strings.TrimSpace(item))
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", n, err)
+			}
+			entries[open].values = append(entries[open].values, v)
+			continue
+		}
+		if line != trimmed {
+			return nil, fmt.Errorf("line %d: unexpected indentation", n)
+		}
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			return nil, fmt.Errorf("line %d: expected \"key: value\"", n)
+		}
+		e := configEntry{key: strings.TrimSpace(key), line: n}
+		value = strings.TrimSpace(value)
+		open = -1
+		switch {
+		case value == "":
+			// values follow as "- item" lines
+			open = len(entries)
+		case strings.HasPrefix(value, "["):
+			if !strings.HasSuffix(value, "]") {
+				return nil, fmt.Errorf("line %d: unterminated list", n)
+			}
+			if inner := strings.TrimSpace(value[1 : len(value)-1]); inner != "" {
+				for _, item := range strings.Split(inner, ",") {
+					v, err := unquote(strings.TrimSpace(item))
+					if err != nil {
+						return nil, fmt.Errorf("line %d: %w", n, err)
+					}
+					e.values = append(e.values, v)
+				}
+			}
+		default:
+			v, err := unquote(value)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", n, err)
+			}
+			e.values = []string{v}
+		}
+		entries = append(entries, e)
+	}
+	return entries, nil
+}
+
+// stripComment drops a "#" comment that starts the line or follows
+// whitespace, outside of quotes.
+func stripComment(line string) string {
+	var quote byte
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '#' && (i == 0 || line[i-1] == ' ' || line[i-1] == '\t'):
+			return line[:i]
+		}
+	}
+	return line
+}
+
+// unquote strips matching single or double quotes around s.
+func unquote(s string) (string, error) {
+	if len(s) > 0 && (s[0] == '"' || s[0] == '\'') {
+		if len(s) < 2 || s[len(s)-1] != s[0] {
+			return "", errors.New("unterminated quoted string")
+		}
+		return s[1 : len(s)-1], nil
+	}
+	return s, nil
+}
+
+// applyConfig sets the flags in fs named by the config file at path to the
+// values it gives, except for flags already set on the command line or from
+// the environment. Keys
+// are flag names; "_" may be used in place of "-".
+func applyConfig(fs *flag.FlagSet, path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	entries, err := parseConfig(data)
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	explicit := map[string]bool{}
+	fs.Visit(func(f *flag.Flag) { explicit[f.Name] = true })
+
+	seen := map[string]bool{}
+	for _, e := range entries {
+		name := strings.ReplaceAll(e.key, "_", "-")
+		f := fs.Lookup(name)
+		if f == nil || name == "config" || name == "version" {
+			return fmt.Errorf("%s:%d: unknown key %q (keys are option names without the leading --, e.g. max-depth)", path, e.line, e.key)
+		}
+		if seen[name] {
+			return fmt.Errorf("%s:%d: duplicate key %q", path, e.line, e.key)
+		}
+		seen[name] = true
+		if explicit[name] {
+			continue
+		}
+		if _, repeatable := f.Value.(*stringList); !repeatable && len(e.values) != 1 {
+			return fmt.Errorf("%s:%d: %s takes a single value", path, e.line, e.key)
+		}
+		for _, v := range e.values {
+			if err := fs.Set(name, v); err != nil {
+				return fmt.Errorf("%s:%d: invalid %s: %w", path, e.line, e.key, err)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"flag"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseConfig(t *testing.T) {
+	data := `# team defaults
+max-depth: 4
+mode: "compat"   # pin loosely
+exclude: [build, 'dist']
+filename:
+  - requirements.txt
+  - requirements-dev.txt
+encoding: utf#8
+`
+	got, err := parseConfig([]byte(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []configEntry{
+		{key: "max-depth", values: []string{"4"}, line: 2},
+		{key: "mode", values: []string{"compat"}, line: 3},
+		{key: "exclude", values: []string{"build", "dist"}, line: 4},
+		{key: "filename", values: []string{"requirements.txt", "requirements-dev.txt"}, line: 5},
+		{key: "encoding", values: []string{"utf#8"}, line: 8},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("parseConfig =\n%+v\nwant\n%+v", got, want)
+	}
+
+	for _, bad := range []string{"- orphan\n", "mode\n", "  indented: 1\n", "exclude: [a, b\n", "mode: \"open\n", "mode: gt\n  - item\n"} {
+		if _, err := parseConfig([]byte(bad)); err == nil {
+			t.Errorf("parseConfig(%q) succeeded, want error", bad)
+		}
+	}
+}
+
+func TestApplyConfig(t *testing.T) {
+	newFlags := func() (*flag.FlagSet, *int, *string, *stringList) {
+		fs := flag.NewFlagSet("test", flag.ContinueOnError)
+		depth := fs.Int("max-depth", 2, "")
+		mode := fs.String("mode", "", "")
+		var excludes stringList
+		fs.Var(&excludes, "exclude", "")
+		return fs, depth, mode, &excludes
+	}
+	dir := t.TempDir()
+	path := filepath.Join(dir, configFileName)
+	writeFile(t, path, "max_depth: 5\nmode: gt\nexclude: [build, dist]\n")
+
+	fs, depth, mode, excludes := newFlags()
+	if err := fs.Parse([]string{"--mode", "compat"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := applyConfig(fs, path); err != nil {
+		t.Fatal(err)
+	}
+	if *depth != 5 || *mode != "compat" || !reflect.DeepEqual([]string(*excludes), []string{"build", "dist"}) {
+		t.Errorf("max-depth=%d mode=%q exclude=%q; want 5, compat (command line wins), [build dist]", *depth, *mode, *excludes)
+	}
+
+	tests := map[string]string{
+		"unknown":  "max-dpeth: 3\n",
+		"invalid":  "max-depth: deep\n",
+		"list":     "mode: [gt, compat]\n",
+		"repeated": "mode: gt\nmode: compat\n",
+	}
+	for name, content := range tests {
+		writeFile(t, path, content)
+		fs, _, _, _ := newFlags()
+		err := applyConfig(fs, path)
+		if err == nil || !strings.Contains(err.Error(), path+":") {
+			t.Errorf("%s: err = %v, want an error naming %s and the line", name, err, path)
+		}
+	}
+	if got := findConfig(t.TempDir(), dir); got != path {
+		t.Errorf("findConfig = %q, want %q", got, path)
+	}
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os/exec"
+	"strings"
+
+	"github.com/bevelwork/quick_pipreqs/pipreqs"
+)
+
+// runDoctor implements the doctor subcommand: it reports where pipreqs and
+// Python resolve to and whether they run, with a hint for anything missing.
+// It returns exitFailure when pipreqs cannot be run.
+func runDoctor(ctx context.Context, w io.Writer, opts pipreqs.Options) int {
+	code := 0
+
+	bin, args := opts.PipreqsCommand("--version")
+	if path, out, err := probe(ctx, bin, args); err != nil {
+		fmt.Fprintf(w, "pipreqs: FAIL %s\n", err)
+		fmt.Fprintf(w, "  hint: install it with: %s\n", installHint(opts))
+		code = exitFailure
+	} else {
+		fmt.Fprintf(w, "pipreqs: ok   %s (%s)\n", path, out)
+	}
+
+	pythons := []string{"python3", "python"}
+	if opts.Python != "" {
+		pythons = []string{opts.Python}
+	}
+	found := false
+	for _, py := range pythons {
+		if path, out, err := probe(ctx, py, []string{"--version"}); err == nil {
+			fmt.Fprintf(w, "python:  ok   %s (%s)\n", path, out)
+			found = true
+			break
+		}
+	}
+	if !found {
+		fmt.Fprintf(w, "python:  FAIL none of %s found in PATH\n", strings.Join(pythons, ", "))
+		if opts.Python != "" {
+			// --python is how pipreqs is run, so nothing works without it
+			code = exitFailure
+		}
+	}
+	return code
+}
+
+// probe resolves bin in PATH and runs it with args, returning the resolved
+// path and its trimmed output.
+func probe(ctx context.Context, bin string, args []string) (string, string, error) {
+	path, err := exec.LookPath(bin)
+	if err != nil {
+		return "", "", err
+	}
+	out, err := pipreqs.ExecRunner{}.Run(ctx, path, args, ".")
+	msg := strings.TrimSpace(string(out))
+	if err != nil {
+		if msg != "" {
+			err = fmt.Errorf("%s %s: %w: %s", path, strings.Join(args, " "), err, msg)
+		} else {
+			err = fmt.Errorf("%s %s: %w", path, strings.Join(args, " "), err)
+		}
+		return path, "", err
+	}
+	return path, msg, nil
+}
+
+// installHint returns the command that installs pipreqs for opts.
+func installHint(opts pipreqs.Options) string {
+	bin, args := installCommand(opts)
+	return bin + " " + strings.Join(args, " ")
+}
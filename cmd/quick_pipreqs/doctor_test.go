@@ -0,0 +1,57 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/bevelwork/quick_pipreqs/pipreqs"
+)
+
+func TestRunDoctor(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses shell scripts as executables")
+	}
+	sh, err := exec.LookPath("sh")
+	if err != nil {
+		t.Skip("sh not available")
+	}
+	tests := []struct {
+		name     string
+		scripts  []string
+		opts     pipreqs.Options
+		wantCode int
+		want     string
+	}{
+		{"usable", []string{"pipreqs", "python3"}, pipreqs.Options{}, 0, "pipreqs: ok"},
+		{"no pipreqs", []string{"python3"}, pipreqs.Options{}, exitFailure, "hint: install it with: pip install pipreqs"},
+		{"no python", []string{"pipreqs"}, pipreqs.Options{}, 0, "python:  FAIL"},
+		{"missing --python", []string{"pipreqs"}, pipreqs.Options{Python: "python3.12"}, exitFailure, "python3.12 -m pip install pipreqs"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bin := t.TempDir()
+			for _, name := range tt.scripts {
+				path := filepath.Join(bin, name)
+				script := "#!" + sh + "\necho " + name + " 1.0\n"
+				if err := os.WriteFile(path, []byte(script), 0o755); err != nil {
+					t.Fatal(err)
+				}
+			}
+			t.Setenv("PATH", bin)
+
+			var out bytes.Buffer
+			if code := runDoctor(context.Background(), &out, tt.opts); code != tt.wantCode {
+				t.Errorf("exit code = %d, want %d\n%s", code, tt.wantCode, out.String())
+			}
+			if !strings.Contains(out.String(), tt.want) {
+				t.Errorf("output does not contain %q:\n%s", tt.want, out.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+)
+
+// envPrefix starts the name of the environment variable for each option.
+const envPrefix = "QUICK_PIPREQS_"
+
+// envName returns the environment variable for the flag called name, e.g.
+// QUICK_PIPREQS_MAX_DEPTH for max-depth.
+func envName(name string) string {
+	return envPrefix + strings.ToUpper(strings.ReplaceAll(name, "-", "_"))
+}
+
+// applyEnv sets the flags in fs that were not given on the command line
+// from their environment variables, looked up with lookup. Repeatable
+// options take a comma-separated list; see splitEnvList.
+func applyEnv(fs *flag.FlagSet, lookup func(string) (string, bool)) error {
+	explicit := map[string]bool{}
+	fs.Visit(func(f *flag.Flag) { explicit[f.Name] = true })
+
+	var err error
+	fs.VisitAll(func(f *flag.Flag) {
+		if err != nil || explicit[f.Name] || f.Name == "version" {
+			return
+		}
+		name := envName(f.Name)
+		v, ok := lookup(name)
+		if !ok {
+			return
+		}
+		values := []string{v}
+		if _, repeatable := f.Value.(*stringList); repeatable {
+			values = splitEnvList(v)
+		}
+		for _, v := range values {
+			if e := fs.Set(f.Name, v); e != nil {
+				err = fmt.Errorf("invalid %s: %w", name, e)
+				return
+			}
+		}
+	})
+	return err
+}
+
+// splitEnvList splits the value of a repeatable option's variable at each
+// comma not escaped as "\,", so that values such as pipreqs' --ignore a,b
+// can contain commas.
+func splitEnvList(v string) []string {
+	var values []string
+	var cur strings.Builder
+	for i := 0; i < len(v); i++ {
+		switch {
+		case v[i] == '\\' && i+1 < len(v) && v[i+1] == ',':
+			cur.WriteByte(',')
+			i++
+		case v[i] == ',':
+			values = append(values, cur.String())
+			cur.Reset()
+		default:
+			cur.WriteByte(v[i])
+		}
+	}
+	return append(values, cur.String())
+}
+
+// printEnvUsage describes the environment variables in the usage message.
+func printEnvUsage(fs *flag.FlagSet) {
+	w := fs.Output()
+	fmt.Fprintln(w, "\nEnvironment:")
+	fmt.Fprintln(w, "  Each option can also be set with an environment variable; options given")
+	fmt.Fprintln(w, "  on the command line take precedence, and both override the config file.")
+	fmt.Fprintln(w, "  Repeatable options take a comma-separated list; write \\, for a comma")
+	fmt.Fprintln(w, "  within a value.")
+	fs.VisitAll(func(f *flag.Flag) {
+		if f.Name != "version" {
+			fmt.Fprintf(w, "    %s\n", envName(f.Name))
+		}
+	})
+}
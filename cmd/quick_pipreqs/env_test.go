@@ -0,0 +1,43 @@
+package main
+
+import (
+	"flag"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestApplyEnv(t *testing.T) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	depth := fs.Int("max-depth", 2, "")
+	concurrency := fs.Int("concurrency", 12, "")
+	var excludes, pipreqsArgs stringList
+	fs.Var(&excludes, "exclude", "")
+	fs.Var(&pipreqsArgs, "pipreqs-arg", "")
+	if err := fs.Parse([]string{"--concurrency", "3"}); err != nil {
+		t.Fatal(err)
+	}
+	env := map[string]string{
+		"QUICK_PIPREQS_MAX_DEPTH":   "5",
+		"QUICK_PIPREQS_CONCURRENCY": "8",
+		"QUICK_PIPREQS_EXCLUDE":     "build,dist",
+		"QUICK_PIPREQS_PIPREQS_ARG": `--ignore,tests\,docs`,
+	}
+	lookup := func(k string) (string, bool) { v, ok := env[k]; return v, ok }
+	if err := applyEnv(fs, lookup); err != nil {
+		t.Fatal(err)
+	}
+	if *depth != 5 || *concurrency != 3 || !reflect.DeepEqual([]string(excludes), []string{"build", "dist"}) {
+		t.Errorf("max-depth=%d concurrency=%d exclude=%q; want 5, 3 (command line wins), [build dist]", *depth, *concurrency, excludes)
+	}
+	if want := []string{"--ignore", "tests,docs"}; !reflect.DeepEqual([]string(pipreqsArgs), want) {
+		t.Errorf("pipreqs-arg = %q, want %q", pipreqsArgs, want)
+	}
+
+	env["QUICK_PIPREQS_MAX_DEPTH"] = "deep"
+	fs = flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.Int("max-depth", 2, "")
+	if err := applyEnv(fs, lookup); err == nil || !strings.Contains(err.Error(), "QUICK_PIPREQS_MAX_DEPTH") {
+		t.Errorf("err = %v, want one naming QUICK_PIPREQS_MAX_DEPTH", err)
+	}
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/bevelwork/quick_pipreqs/pipreqs"
+)
+
+// installCommand returns the command that installs pipreqs for opts: pip of
+// the --python interpreter if one is set, else pip from PATH.
+func installCommand(opts pipreqs.Options) (string, []string) {
+	if opts.Python != "" {
+		return opts.Python, []string{"-m", "pip", "install", "pipreqs"}
+	}
+	return "pip", []string{"install", "pipreqs"}
+}
+
+// installPipreqs runs installCommand, streaming its output to out if not
+// nil. Otherwise the output is only shown when the install fails.
+func installPipreqs(ctx context.Context, opts pipreqs.Options, out io.Writer) error {
+	bin, args := installCommand(opts)
+	cmd := exec.CommandContext(ctx, bin, args...)
+	cmd.Env = os.Environ()
+	var buf bytes.Buffer
+	if out == nil {
+		out = &buf
+	}
+	cmd.Stdout = out
+	cmd.Stderr = out
+	if err := cmd.Run(); err != nil {
+		msg := strings.TrimSpace(buf.String())
+		if msg == "" {
+			return fmt.Errorf("%s %s: %w", bin, strings.Join(args, " "), err)
+		}
+		return fmt.Errorf("%s %s: %w\n%s", bin, strings.Join(args, " "), err, msg)
+	}
+	return nil
+}
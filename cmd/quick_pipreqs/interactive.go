@@ -0,0 +1,59 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/bevelwork/quick_pipreqs/pipreqs"
+)
+
+// prompter asks whether to apply each change of an --interactive run,
+// showing its diff on out and reading the answer from in. It is not safe for
+// concurrent use; interactive runs process one directory at a time.
+type prompter struct {
+	in    *bufio.Reader
+	out   io.Writer
+	color bool
+	quit  func() // stops the run after a "q" or the end of input
+
+	all  bool // "a" was answered: apply the rest without asking
+	done bool // "q" was answered: decline the rest
+}
+
+// confirm shows r's diff and asks whether to apply it; it is used as
+// pipreqs.Options.Confirm.
+func (p *prompter) confirm(r pipreqs.Result) bool {
+	switch {
+	case p.done:
+		return false
+	case p.all:
+		return true
+	}
+	writeDiff(p.out, r, p.color)
+	for {
+		fmt.Fprintf(p.out, "apply changes to %s? [y/N/a/q] ", r.Path())
+		line, err := p.in.ReadString('\n')
+		answer := strings.ToLower(strings.TrimSpace(line))
+		if err != nil && answer == "" {
+			// no one left to ask
+			fmt.Fprintln(p.out)
+			answer = "q"
+		}
+		switch answer {
+		case "y", "yes":
+			return true
+		case "", "n", "no":
+			return false
+		case "a", "all":
+			p.all = true
+			return true
+		case "q", "quit":
+			p.done = true
+			p.quit()
+			return false
+		}
+		fmt.Fprintln(p.out, "answer y (yes), n (no), a (apply all the rest), or q (quit)")
+	}
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"bufio"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/bevelwork/quick_pipreqs/pipreqs"
+)
+
+func TestPrompterConfirm(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  []bool // answers for five changes in a row
+		quit  bool
+	}{
+		{"yes and no", "y\nn\n\nyes\nno\n", []bool{true, false, false, true, false}, false},
+		{"all", "n\na\n", []bool{false, true, true, true, true}, false},
+		{"quit", "y\nq\n", []bool{true, false, false, false, false}, true},
+		{"unknown answer asks again", "maybe\ny\nn\nn\nn\nn\n", []bool{true, false, false, false, false}, false},
+		{"end of input quits", "y\n", []bool{true, false, false, false, false}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			quit := false
+			p := &prompter{in: bufio.NewReader(strings.NewReader(tt.input)), out: io.Discard, quit: func() { quit = true }}
+			var got []bool
+			for range tt.want {
+				got = append(got, p.confirm(pipreqs.Result{Dir: "a", Filename: "requirements.txt"}))
+			}
+			if !reflect.DeepEqual(got, tt.want) || quit != tt.quit {
+				t.Errorf("answers = %v, quit = %v; want %v, %v", got, quit, tt.want, tt.quit)
+			}
+		})
+	}
+}
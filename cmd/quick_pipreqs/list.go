@@ -0,0 +1,30 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/bevelwork/quick_pipreqs/pipreqs"
+)
+
+// runList implements the list subcommand: it prints the requirements files
+// a run with cfg would process, in processing order, without running
+// pipreqs. It returns the process exit code.
+func runList(cfg pipreqs.Config, jsonOut bool) int {
+	targets, err := pipreqs.FindTargets(cfg)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "error:", err)
+		return exitFailure
+	}
+	if jsonOut {
+		if err := writeJSONTargets(os.Stdout, targets); err != nil {
+			fmt.Fprintln(os.Stderr, "error:", err)
+			return exitFailure
+		}
+		return 0
+	}
+	for _, t := range targets {
+		fmt.Println(t.Path())
+	}
+	return 0
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// lockFileName is the lock taken in each root when --lock-file is not set.
+const lockFileName = ".quick_pipreqs.lock"
+
+// lockPollInterval is how often a waiting run retries a held lock.
+const lockPollInterval = 100 * time.Millisecond
+
+// errLocked is returned by openLocked when another process holds the lock.
+var errLocked = errors.New("locked")
+
+// fileLock is an exclusive lock held through a file that records the
+// holder's PID.
+type fileLock struct {
+	f    *os.File
+	path string
+}
+
+// lockPaths returns the lock files a run over roots takes: lockFile if set,
+// otherwise one in each root, sorted so that waiting runs cannot deadlock.
+func lockPaths(lockFile string, roots []string) ([]string, error) {
+	if lockFile != "" {
+		return []string{lockFile}, nil
+	}
+	var paths []string
+	for _, root := range roots {
+		abs, err := filepath.Abs(filepath.Join(root, lockFileName))
+		if err != nil {
+			return nil, err
+		}
+		paths = append(paths, abs)
+	}
+	slices.Sort(paths)
+	return slices.Compact(paths), nil
+}
+
+// acquireLock takes the lock at path, retrying for up to wait while another
+// process holds it.
+func acquireLock(ctx context.Context, path string, wait time.Duration) (*fileLock, error) {
+	deadline := time.Now().Add(wait)
+	for {
+		f, err := openLocked(path)
+		if err == nil {
+			if err := f.Truncate(0); err == nil {
+				_, _ = f.WriteAt([]byte(strconv.Itoa(os.Getpid())+"\n"), 0)
+			}
+			return &fileLock{f: f, path: path}, nil
+		}
+		if !errors.Is(err, errLocked) {
+			return nil, err
+		}
+		if !time.Now().Before(deadline) {
+			holder := "another quick_pipreqs run"
+			if data, err := os.ReadFile(path); err == nil {
+				if pid := strings.TrimSpace(string(data)); pid != "" {
+					holder += " (pid " + pid + ")"
+				}
+			}
+			return nil, fmt.Errorf("%s holds %s; wait for it to finish or pass --lock-wait", holder, path)
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(lockPollInterval):
+		}
+	}
+}
+
+// release removes the lock file and drops the lock. The file goes first,
+// so a run that opened it in the meantime notices it is stale.
+func (l *fileLock) release() {
+	_ = os.Remove(l.path)
+	_ = l.f.Close()
+}
@@ -0,0 +1,20 @@
+//go:build !unix
+
+package main
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+)
+
+// openLocked creates path exclusively; its existence is the lock. A run
+// that dies without releasing it leaves the file behind, to be removed by
+// hand.
+func openLocked(path string) (*os.File, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0o644)
+	if errors.Is(err, fs.ErrExist) {
+		return nil, errLocked
+	}
+	return f, err
+}
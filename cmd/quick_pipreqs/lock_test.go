@@ -0,0 +1,56 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAcquireLock(t *testing.T) {
+	path := filepath.Join(t.TempDir(), lockFileName)
+	ctx := context.Background()
+	l, err := acquireLock(ctx, path, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := os.ReadFile(path); strings.TrimSpace(string(data)) != strconv.Itoa(os.Getpid()) {
+		t.Errorf("lock file holds %q, want our pid", data)
+	}
+
+	_, err = acquireLock(ctx, path, 0)
+	if err == nil || !strings.Contains(err.Error(), "pid "+strconv.Itoa(os.Getpid())) {
+		t.Fatalf("second acquire = %v, want a held-lock error naming the pid", err)
+	}
+
+	go func() {
+		time.Sleep(2 * lockPollInterval)
+		l.release()
+	}()
+	l2, err := acquireLock(ctx, path, 5*time.Second)
+	if err != nil {
+		t.Fatalf("waiting acquire = %v", err)
+	}
+	l2.release()
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("lock file still exists after release: %v", err)
+	}
+}
+
+func TestLockPaths(t *testing.T) {
+	got, err := lockPaths("", []string{"b", "a", "b"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	a, _ := filepath.Abs(filepath.Join("a", lockFileName))
+	b, _ := filepath.Abs(filepath.Join("b", lockFileName))
+	if len(got) != 2 || got[0] != a || got[1] != b {
+		t.Errorf("lockPaths = %q, want [%s %s]", got, a, b)
+	}
+	if got, _ := lockPaths("/tmp/x.lock", []string{"a"}); len(got) != 1 || got[0] != "/tmp/x.lock" {
+		t.Errorf("lockPaths with --lock-file = %q", got)
+	}
+}
@@ -0,0 +1,36 @@
+//go:build unix
+
+package main
+
+import (
+	"errors"
+	"os"
+	"syscall"
+)
+
+// openLocked opens path, creating it if needed, and takes an exclusive
+// flock on it without blocking. The kernel drops the lock when the process
+// dies, so a killed run never leaves a stale lock behind.
+func openLocked(path string) (*os.File, error) {
+	for {
+		f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o644)
+		if err != nil {
+			return nil, err
+		}
+		if err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB); err != nil {
+			f.Close()
+			if errors.Is(err, syscall.EWOULDBLOCK) {
+				return nil, errLocked
+			}
+			return nil, err
+		}
+		// the holder may have removed the file between our open and flock;
+		// a lock on a removed file protects nothing
+		held, err1 := f.Stat()
+		current, err2 := os.Stat(path)
+		if err1 == nil && err2 == nil && os.SameFile(held, current) {
+			return f, nil
+		}
+		f.Close()
+	}
+}
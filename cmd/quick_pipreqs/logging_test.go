@@ -0,0 +1,40 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestNewLogger(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := newLogger(&buf, "WARN", "json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger.Info("hidden")
+	logger.Warn("failed", "path", "a/requirements.txt")
+	var entry map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatalf("log output %q is not a single JSON object: %v", buf.String(), err)
+	}
+	if entry["msg"] != "failed" || entry["path"] != "a/requirements.txt" {
+		t.Errorf("entry = %v", entry)
+	}
+
+	buf.Reset()
+	if logger, err = newLogger(&buf, "debug", "text"); err != nil {
+		t.Fatal(err)
+	}
+	logger.Debug("target", "index", 1)
+	if !strings.Contains(buf.String(), "level=DEBUG msg=target index=1") {
+		t.Errorf("text output = %q", buf.String())
+	}
+
+	for _, tt := range [][2]string{{"loud", "text"}, {"info", "xml"}} {
+		if _, err := newLogger(&buf, tt[0], tt[1]); err == nil {
+			t.Errorf("newLogger(%q, %q) succeeded, want an error", tt[0], tt[1])
+		}
+	}
+}
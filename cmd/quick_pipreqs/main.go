@@ -1,258 +1,1024 @@
 package main
 
 import (
+	"bufio"
+	"bytes"
 	"context"
-	"crypto/sha256"
 	"errors"
 	"flag"
 	"fmt"
 	"io"
-	"io/fs"
-	"log"
 	"os"
-	"os/exec"
+	"os/signal"
+	"path"
 	"path/filepath"
-	"sort"
+	"runtime"
+	"slices"
 	"strings"
 	"sync"
-	"sync/atomic"
+	"syscall"
+	"time"
 
+	"github.com/bevelwork/quick_pipreqs/pipreqs"
 	"github.com/bevelwork/quick_pipreqs/version"
+	"go.opentelemetry.io/otel/trace"
 )
 
 func main() {
 	var (
-		dryRun      bool
-		maxDepth    int
-		concurrency int
-		verbose     bool
+		dryRun            bool
+		maxDepth          int
+		concurrency       int
+		verbose           bool
+		quiet             bool
+		logLevel          string
+		logFormat         string
+		logFile           string
+		errorLog          string
+		metricsFile       string
+		aggregate         string
+		detectConflicts   bool
+		failOnConflict    bool
+		otelEnabled       bool
+		preHook           string
+		postHook          string
+		colorMode         string
+		showStats         bool
+		cpuProfile        string
+		memProfile        string
+		mode              string
+		encoding          string
+		pipreqsArgs       stringList
+		excludes          stringList
+		noDefaultExcludes bool
+		respectGitignore  bool
+		jsonOut           bool
+		format            string
+		github            bool
+		exitCode          bool
+		check             bool
+		keepBackups       bool
+		noBackup          bool
+		backupSuffix      string
+		backupDir         string
+		force             bool
+		skipDirty         bool
+		filenames         stringList
+		requirePython     bool
+		scanNotebooks     bool
+		preserveHeader    bool
+		merge             bool
+		preserveVCS       bool
+		normalize         bool
+		validate          bool
+		maxRemovedPct     int
+		showDiff          bool
+		showProgress      bool
+		timeout           time.Duration
+		deadline          time.Duration
+		pipreqsPath       string
+		python            string
+		autoInstall       bool
+		fromFile          string
+		followSymlinks    bool
+		noFollowSymlinks  bool
+		discoveryWorkers  int
+		changeDetection   string
+		hashAlgorithm     string
+		stateFile         string
+		noCache           bool
+		watch             bool
+		interactive       bool
+		changedSince      string
+		gitCommit         bool
+		gitStash          bool
+		commitMessage     string
+		watchDebounce     time.Duration
+		watchRescan       time.Duration
+		lockFile          string
+		lockWait          time.Duration
+		failFast          bool
+		maxErrors         int
+		preferVenv        bool
+		retries           int
+		retryDelay        time.Duration
 	)
-	flag.BoolVar(&dryRun, "dry-run", false, "print actions without executing")
+	flag.BoolVar(&dryRun, "dry-run", false, "run pipreqs into a temporary file and report what would change without touching requirements.txt")
 	showVersion := flag.Bool("version", false, "print version and exit")
-	flag.IntVar(&maxDepth, "max-depth", 2, "maximum recursion depth (0 = only root)")
-	flag.IntVar(&concurrency, "concurrency", 12, "max concurrent updates (1-12)")
-	flag.BoolVar(&verbose, "verbose", false, "print verbose output")
+	configPath := flag.String("config", "", "read default options from this file (default: "+configFileName+" in <path> or the working directory)")
+	flag.IntVar(&maxDepth, "max-depth", 2, "descend at most this many directories below each path (0 = only the path itself, -1 = unlimited)")
+	flag.IntVar(&concurrency, "concurrency", pipreqs.DefaultConcurrency(), "max concurrent updates, one per CPU unless set (at least 1)")
+	flag.BoolVar(&verbose, "verbose", false, "print verbose output (and log at debug level unless --log-level is set)")
+	flag.BoolVar(&quiet, "quiet", false, "log errors only; print only the summary and errors (like --log-level error)")
+	flag.StringVar(&logLevel, "log-level", "info", "minimum level of log messages: debug, info, warn, or error")
+	flag.StringVar(&logFormat, "log-format", "text", "log message format: text or json")
+	flag.StringVar(&logFile, "log-file", "", "append log messages to this file instead of printing them")
+	flag.StringVar(&preHook, "pre-hook", "", "shell command to run in each directory before regenerating it; failing skips the directory")
+	flag.StringVar(&postHook, "post-hook", "", "shell command to run in each directory after regenerating it; failing fails the directory")
+	flag.BoolVar(&otelEnabled, "otel", false, "export OpenTelemetry traces of the run over OTLP, configured by the standard OTEL_EXPORTER_OTLP_* variables")
+	flag.StringVar(&metricsFile, "metrics-file", "", "after the run, write its counts and duration to this file in the Prometheus text format (for the node_exporter textfile collector)")
+	flag.StringVar(&aggregate, "aggregate", "", "after the run, merge every resulting requirements file into this single deduplicated file and report conflicting pins")
+	flag.BoolVar(&detectConflicts, "detect-conflicts", false, "after the run, report packages pinned to different versions by different requirements files")
+	flag.BoolVar(&failOnConflict, "fail-on-conflict", false, "like --detect-conflicts, but exit 1 if any package is pinned to different versions")
+	flag.StringVar(&errorLog, "error-log", "", "append the full output of every failure to this file and print one line per failure instead")
+	flag.BoolVar(&check, "check", false, fmt.Sprintf("like --dry-run, but list stale requirements files and exit %d if any", exitChanged))
+	flag.BoolVar(&exitCode, "exit-code", false, fmt.Sprintf("exit %d when any requirements file was (or would be) updated", exitChanged))
+	flag.BoolVar(&keepBackups, "keep-backups", false, "keep requirements.txt.bak even when the file did not change")
+	flag.BoolVar(&noBackup, "no-backup", false, "replace requirements.txt without keeping a .bak")
+	flag.StringVar(&backupSuffix, "backup-suffix", ".bak", "suffix appended to backup file names")
+	flag.StringVar(&backupDir, "backup-dir", "", "write backups into a tree mirroring their absolute paths under this directory instead of alongside the original")
+	flag.Var(&filenames, "filename", "requirements file name to discover and regenerate (repeatable; default "+pipreqs.DefaultFilename+")")
+	flag.BoolVar(&requirePython, "require-python-files", true, "skip directories without any .py files instead of running pipreqs")
+	flag.BoolVar(&scanNotebooks, "scan-notebooks", false, "include imports from Jupyter notebooks (.ipynb)")
+	flag.BoolVar(&preserveHeader, "preserve-header", false, "keep the leading # comment block of the existing requirements file")
+	flag.BoolVar(&merge, "merge", false, "keep version specifiers from the existing file for packages pipreqs still detects")
+	flag.BoolVar(&preserveVCS, "preserve-vcs", false, "re-append VCS and URL requirements from the existing file (default: on with --merge)")
+	flag.BoolVar(&normalize, "normalize", false, "rewrite package names in PEP 503 normalized form and sort them")
+	flag.BoolVar(&validate, "validate", false, "fail (and restore the backup) if the generated file has unparseable lines")
+	flag.IntVar(&maxRemovedPct, "max-removed-pct", 50, "fail (and restore the backup) if more than this percentage of packages would be removed; 0 disables")
+	flag.BoolVar(&skipDirty, "skip-dirty", false, "skip requirements files with uncommitted changes in their git repository")
+	flag.BoolVar(&force, "force", false, "bypass safety checks (--max-removed-pct; --skip-dirty; --state-file; restore: overwrite files edited since their backup)")
+	flag.StringVar(&colorMode, "color", "auto", "color output: auto (terminals, unless NO_COLOR is set), always, or never")
+	flag.StringVar(&changeDetection, "change-detection", pipreqs.ChangeHash, "how to tell whether pipreqs changed a file: hash (hash the contents) or size (compare sizes, then the contents, without hashing)")
+	flag.StringVar(&hashAlgorithm, "hash", pipreqs.HashSHA256, "hash used to detect changed files: sha256, xxhash, or crc32 (only used for comparison)")
+	flag.StringVar(&stateFile, "state-file", "", "skip directories whose Python sources are unchanged since the last run recorded in this JSON manifest")
+	flag.BoolVar(&noCache, "no-cache", false, "with --state-file, discard the cached pipreqs output and run pipreqs everywhere")
+	flag.StringVar(&changedSince, "changed-since", "", "only process directories with .py files changed since this git ref (git diff --name-only)")
+	flag.BoolVar(&gitCommit, "git-commit", false, "after a run without failures, commit the requirements files it updated (and nothing else) with git")
+	flag.BoolVar(&gitStash, "git-stash", false, "stash uncommitted changes to tracked files before the run and pop them afterward")
+	flag.StringVar(&commitMessage, "commit-message", defaultCommitMessage, "with --git-commit, the commit message")
+	flag.BoolVar(&interactive, "interactive", false, "show each change and ask on stdin whether to apply it, processing one directory at a time")
+	flag.BoolVar(&watch, "watch", false, "after the first pass, keep running and regenerate requirements files when their .py sources change, until interrupted")
+	flag.DurationVar(&watchDebounce, "watch-debounce", pipreqs.DefaultDebounce, "with --watch, wait this long after the last change before regenerating")
+	flag.DurationVar(&watchRescan, "watch-rescan", pipreqs.DefaultRescan, "with --watch, look for new requirements files this often")
+	flag.BoolVar(&showDiff, "diff", false, "print a unified diff of every changed requirements file (colored on a terminal)")
+	flag.DurationVar(&timeout, "timeout", 0, "abort pipreqs in a directory after this long (e.g. 2m) and restore its backup; 0 means no limit")
+	flag.StringVar(&pipreqsPath, "pipreqs", "", "pipreqs executable to run (default: pipreqs from PATH)")
+	flag.StringVar(&python, "python", "", "run pipreqs as a module of this Python interpreter instead of the pipreqs script")
+	flag.BoolVar(&autoInstall, "auto-install", false, "if pipreqs is missing, pip install it (with --python's pip, if given) before running")
+	flag.BoolVar(&preferVenv, "prefer-venv", false, "run pipreqs inside a .venv or venv next to each requirements file, if present")
+	flag.IntVar(&retries, "retries", 0, "retry a failed pipreqs run this many times before giving up")
+	flag.DurationVar(&retryDelay, "retry-delay", 2*time.Second, "pause between pipreqs retries")
+	flag.BoolVar(&failFast, "fail-fast", false, "stop at the first failed directory; running ones are restored and queued ones skipped")
+	flag.IntVar(&maxErrors, "max-errors", 0, "stop once this many directories have failed, like --fail-fast; 0 means never")
+	flag.StringVar(&lockFile, "lock-file", "", "lock this file for the duration of the run (default: "+lockFileName+" in each path)")
+	flag.DurationVar(&lockWait, "lock-wait", 0, "if another run holds the lock, wait up to this long for it instead of failing at once")
+	flag.DurationVar(&deadline, "deadline", 0, "stop the whole run after this long; in-flight directories are restored and queued ones skipped (0 = no limit)")
+	flag.DurationVar(&deadline, "max-runtime", 0, "alias for --deadline")
+	flag.StringVar(&cpuProfile, "cpuprofile", "", "write a CPU profile of the run to this file")
+	flag.StringVar(&memProfile, "memprofile", "", "write a heap profile to this file when the run ends")
+	flag.BoolVar(&showStats, "stats", false, "print p50/p90/p99 and maximum per-file durations after the summary")
+	flag.BoolVar(&showProgress, "progress", false, "show a live progress line on stderr (terminals only; ignored with --json)")
+	flag.BoolVar(&jsonOut, "json", false, "print a JSON summary to stdout (logs go to stderr); same as --format json")
+	flag.BoolVar(&github, "github", false, "print GitHub Actions annotations for failed and updated directories (default: on when GITHUB_ACTIONS is true)")
+	flag.StringVar(&format, "format", formatText, "summary format: text, json, markdown (a table for pull requests), or csv; logs go to stderr unless text")
+	flag.StringVar(&mode, "mode", "", "pipreqs version pinning mode: no-pin, gt, compat (default: pipreqs default)")
+	flag.StringVar(&encoding, "encoding", "", "source file encoding passed to pipreqs (default: pipreqs default)")
+	flag.Var(&excludes, "exclude", "glob pattern of directories to skip during discovery (repeatable)")
+	flag.BoolVar(&noDefaultExcludes, "no-default-excludes", false, "also descend into "+strings.Join(pipreqs.DefaultExcludeDirs, ", "))
+	flag.BoolVar(&followSymlinks, "follow-symlinks", false, "descend into symlinked directories during discovery (each real directory is visited once)")
+	flag.BoolVar(&noFollowSymlinks, "no-follow-symlinks", false, "refuse requirements files that are symlinks instead of regenerating the files they point to")
+	flag.IntVar(&discoveryWorkers, "discovery-workers", 1, "directories read in parallel during discovery (sequential with --respect-gitignore or --follow-symlinks)")
+	flag.BoolVar(&respectGitignore, "respect-gitignore", false, "skip paths ignored by .gitignore files")
+	flag.StringVar(&fromFile, "from-file", "", "process the directories listed in this file (one per line, - for stdin) instead of discovering them")
+	flag.Var(&pipreqsArgs, "pipreqs-arg", "extra argument passed through to pipreqs (repeatable)")
 	flag.Usage = func() {
-		fmt.Fprintf(os.Stderr, "Usage: %s [options] <path>\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "Usage: %s [command] [options] <path>...\n", os.Args[0])
+		fmt.Fprintln(os.Stderr, "\nCommands:")
+		fmt.Fprintln(os.Stderr, "  (none)  regenerate requirements.txt files")
+		fmt.Fprintln(os.Stderr, "  clean   remove leftover backup files")
+		fmt.Fprintln(os.Stderr, "  restore roll requirements files back from their backups")
+		fmt.Fprintln(os.Stderr, "  list    print the requirements files that would be processed")
+		fmt.Fprintln(os.Stderr, "  doctor  check that pipreqs and Python can be found and run")
+		fmt.Fprintln(os.Stderr, "\nOptions:")
 		flag.PrintDefaults()
+		printEnvUsage(flag.CommandLine)
 	}
-	flag.Parse()
-	if flag.NArg() < 1 {
-		if *showVersion {
-			fmt.Println(version.Full)
-			return
-		}
-		flag.Usage()
-		os.Exit(2)
+	command, args := splitCommand(os.Args[1:])
+	_ = flag.CommandLine.Parse(args)
+	// precedence: command line, then environment, then config file
+	if err := applyEnv(flag.CommandLine, os.LookupEnv); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		exit(exitUsage)
 	}
 	if *showVersion {
 		fmt.Println(version.Full)
 		return
 	}
 
-	// log discovered directories
-	logger := log.New(os.Stdout, "", log.LstdFlags)
+	// options from a config file fill in whatever the command line left
+	// unset; it is read before the usage check, since it may set from-file
+	if *configPath == "" {
+		dirs := []string{"."}
+		if flag.NArg() > 0 {
+			dirs = []string{flag.Arg(0), "."}
+		}
+		*configPath = findConfig(dirs...)
+	}
+	if *configPath != "" {
+		if err := applyConfig(flag.CommandLine, *configPath); err != nil {
+			fmt.Fprintln(os.Stderr, "invalid config:", err)
+			exit(exitUsage)
+		}
+	}
+	if flag.NArg() < 1 && command != "doctor" && fromFile == "" {
+		flag.Usage()
+		exit(exitUsage)
+	}
 
-	// Validation
-	pipreqsVersion, err := runCmd("pipreqs", []string{"--version"}, ".")
+	stopProfiling, err := startProfiling(cpuProfile, memProfile)
 	if err != nil {
-		logger.Fatalf("error: pipreqs not found in PATH: %v", err)
-		return
+		fmt.Fprintln(os.Stderr, err)
+		exit(exitUsage)
 	}
-	logger.Printf("pipreqs version: %s", pipreqsVersion)
+	exitHooks = append(exitHooks, stopProfiling)
 
-	root := flag.Arg(0)
+	if colorMode != "auto" && colorMode != "always" && colorMode != "never" {
+		fmt.Fprintln(os.Stderr, "invalid --color:", colorMode, "(must be one of: auto, always, never)")
+		exit(exitUsage)
+	}
+	if quiet && verbose {
+		fmt.Fprintln(os.Stderr, "--quiet and --verbose are mutually exclusive")
+		exit(exitUsage)
+	}
 
-	reqDirs, err := findRequirementsDirs(root, maxDepth)
+	switch {
+	case !slices.Contains(formats, format):
+		fmt.Fprintln(os.Stderr, "invalid --format:", format, "(must be one of: "+strings.Join(formats, ", ")+")")
+		exit(exitUsage)
+	case jsonOut && flagSet("format") && format != formatJSON:
+		fmt.Fprintln(os.Stderr, "--json cannot be combined with --format", format)
+		exit(exitUsage)
+	case jsonOut:
+		format = formatJSON
+	}
+	jsonOut = format == formatJSON
+	if !flagSet("github") && os.Getenv("GITHUB_ACTIONS") == "true" {
+		github = true
+	}
+
+	// log discovered directories; keep stdout clean for --json and the
+	// other machine-readable formats
+	logOut := os.Stdout
+	if format != formatText || command == "list" {
+		logOut = os.Stderr
+	}
+	if !flagSet("log-level") {
+		switch {
+		case verbose:
+			logLevel = "debug"
+		case quiet:
+			logLevel = "error"
+		}
+	}
+	var logDest io.Writer = logOut
+	if logFile != "" {
+		f, err := os.OpenFile(logFile, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "invalid --log-file:", err)
+			exit(exitUsage)
+		}
+		defer f.Close()
+		logDest = f
+	}
+	var errorLogFile *os.File
+	if errorLog != "" {
+		if errorLogFile, err = os.OpenFile(errorLog, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644); err != nil {
+			fmt.Fprintln(os.Stderr, "invalid --error-log:", err)
+			exit(exitUsage)
+		}
+		defer errorLogFile.Close()
+	}
+	logger, err := newLogger(logDest, logLevel, logFormat)
 	if err != nil {
-		fmt.Fprintln(os.Stderr, "error:", err)
-		os.Exit(1)
+		fmt.Fprintln(os.Stderr, err)
+		exit(exitUsage)
 	}
+	if *configPath != "" {
+		logger.Debug("using config", "path", *configPath)
+	}
+
+	// Create context for cancellation and coordination; SIGINT/SIGTERM
+	// cancel it so in-flight pipreqs runs are killed and backups restored.
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, os.Interrupt, syscall.SIGTERM)
+	// ctx is replaced by derived contexts below; the goroutine only ever
+	// sees this one
+	done := ctx.Done()
+	go func() {
+		select {
+		case sig := <-sigs:
+			logger.Warn("received signal; cancelling", "signal", sig.String())
+			// a second signal terminates immediately
+			signal.Stop(sigs)
+			cancel()
+		case <-done:
+		}
+	}()
 
-	if len(reqDirs) == 0 {
-		fmt.Println("no requirements.txt found; running pipreqs in root:", root)
-		reqDirs = []string{root}
+	if check {
+		// check never writes; it is a dry run with an assertion on the result
+		dryRun = true
+		exitCode = true
 	}
 
-	// deterministic processing order
-	sort.Strings(reqDirs)
+	if noBackup && keepBackups {
+		fmt.Fprintln(os.Stderr, "--no-backup and --keep-backups are mutually exclusive")
+		exit(exitUsage)
+	}
 
-	logger.Printf("discovered %d directories to process", len(reqDirs))
-	if verbose {
-		for _, d := range reqDirs {
-			logger.Println(" -", d)
+	if len(filenames) == 0 {
+		filenames = stringList{pipreqs.DefaultFilename}
+	}
+	for _, name := range filenames {
+		if name == "" || name != filepath.Base(name) {
+			fmt.Fprintln(os.Stderr, "invalid --filename:", name, "(must be a plain file name)")
+			exit(exitUsage)
 		}
 	}
 
-	if concurrency < 1 {
-		fmt.Fprintln(os.Stderr, "invalid --concurrency:", concurrency, "(must be >= 1)")
-		os.Exit(2)
+	if backupSuffix == "" && backupDir == "" {
+		fmt.Fprintln(os.Stderr, "invalid --backup-suffix: must not be empty unless --backup-dir is set")
+		exit(exitUsage)
+	}
+
+	if maxDepth < -1 {
+		fmt.Fprintln(os.Stderr, "invalid --max-depth:", maxDepth, "(must be >= 0, or -1 for unlimited)")
+		exit(exitUsage)
+	}
+
+	if maxRemovedPct < 0 || maxRemovedPct > 100 {
+		fmt.Fprintln(os.Stderr, "invalid --max-removed-pct:", maxRemovedPct, "(must be 0-100)")
+		exit(exitUsage)
+	}
+
+	if timeout < 0 {
+		fmt.Fprintln(os.Stderr, "invalid --timeout:", timeout, "(must be >= 0)")
+		exit(exitUsage)
+	}
+	if flagSet("pipreqs") && pipreqsPath == "" {
+		fmt.Fprintln(os.Stderr, "invalid --pipreqs: must not be empty")
+		exit(exitUsage)
+	}
+	if flagSet("python") && python == "" {
+		fmt.Fprintln(os.Stderr, "invalid --python: must not be empty")
+		exit(exitUsage)
+	}
+	if pipreqsPath != "" && python != "" {
+		fmt.Fprintln(os.Stderr, "--pipreqs and --python are mutually exclusive")
+		exit(exitUsage)
+	}
+	if retries < 0 {
+		fmt.Fprintln(os.Stderr, "invalid --retries:", retries, "(must be >= 0)")
+		exit(exitUsage)
+	}
+	if discoveryWorkers < 1 {
+		fmt.Fprintln(os.Stderr, "invalid --discovery-workers:", discoveryWorkers, "(must be >= 1)")
+		exit(exitUsage)
+	}
+	if maxErrors < 0 {
+		fmt.Fprintln(os.Stderr, "invalid --max-errors:", maxErrors, "(must be >= 0)")
+		exit(exitUsage)
+	}
+	if retryDelay < 0 {
+		fmt.Fprintln(os.Stderr, "invalid --retry-delay:", retryDelay, "(must be >= 0)")
+		exit(exitUsage)
 	}
-	if concurrency > 12 {
-		concurrency = 12
+	if deadline < 0 {
+		fmt.Fprintln(os.Stderr, "invalid --deadline:", deadline, "(must be >= 0)")
+		exit(exitUsage)
+	}
+	if lockWait < 0 {
+		fmt.Fprintln(os.Stderr, "invalid --lock-wait:", lockWait, "(must be >= 0)")
+		exit(exitUsage)
+	}
+	if watchDebounce <= 0 || watchRescan <= 0 {
+		fmt.Fprintln(os.Stderr, "invalid --watch-debounce or --watch-rescan: must be > 0")
+		exit(exitUsage)
+	}
+	if watch && (check || format != formatText) {
+		fmt.Fprintln(os.Stderr, "--watch cannot be combined with --check, --json, or --format other than text")
+		exit(exitUsage)
+	}
+	if failOnConflict {
+		detectConflicts = true
+	}
+	if (aggregate != "" || detectConflicts) && watch {
+		fmt.Fprintln(os.Stderr, "--aggregate, --detect-conflicts, and --fail-on-conflict cannot be combined with --watch")
+		exit(exitUsage)
+	}
+	if gitStash && (dryRun || watch) {
+		fmt.Fprintln(os.Stderr, "--git-stash cannot be combined with --dry-run, --check, or --watch")
+		exit(exitUsage)
+	}
+	if gitCommit && (dryRun || watch) {
+		fmt.Fprintln(os.Stderr, "--git-commit cannot be combined with --dry-run, --check, or --watch")
+		exit(exitUsage)
+	}
+	if gitCommit && strings.TrimSpace(commitMessage) == "" {
+		fmt.Fprintln(os.Stderr, "invalid --commit-message: must not be empty")
+		exit(exitUsage)
+	}
+	if interactive && (dryRun || format != formatText || watch) {
+		fmt.Fprintln(os.Stderr, "--interactive cannot be combined with --dry-run, --check, --json, --format other than text, or --watch")
+		exit(exitUsage)
+	}
+	if interactive && fromFile == "-" {
+		fmt.Fprintln(os.Stderr, "--interactive reads answers from stdin, so it cannot be combined with --from-file -")
+		exit(exitUsage)
 	}
 
-	// early check for pipreqs availability (skip in dry-run)
-	if !dryRun {
-		if _, err := exec.LookPath("pipreqs"); err != nil {
-			fmt.Fprintln(os.Stderr, "pipreqs not found in PATH:", err)
-			os.Exit(1)
+	if !pipreqs.ValidMode(mode) {
+		fmt.Fprintln(os.Stderr, "invalid --mode:", mode, "(must be one of: no-pin, gt, compat)")
+		exit(exitUsage)
+	}
+	if !pipreqs.ValidChangeDetection(changeDetection) {
+		fmt.Fprintln(os.Stderr, "invalid --change-detection:", changeDetection, "(must be hash or size)")
+		exit(exitUsage)
+	}
+	if !pipreqs.ValidHashAlgorithm(hashAlgorithm) {
+		fmt.Fprintln(os.Stderr, "invalid --hash:", hashAlgorithm, "(must be one of: sha256, xxhash, crc32)")
+		exit(exitUsage)
+	}
+	if flagSet("encoding") && encoding == "" {
+		fmt.Fprintln(os.Stderr, "invalid --encoding: must not be empty")
+		exit(exitUsage)
+	}
+	for _, a := range pipreqsArgs {
+		if a == "" {
+			fmt.Fprintln(os.Stderr, "invalid --pipreqs-arg: must not be empty")
+			exit(exitUsage)
+		}
+	}
+	for _, p := range excludes {
+		if _, err := path.Match(filepath.ToSlash(p), ""); err != nil {
+			fmt.Fprintln(os.Stderr, "invalid --exclude:", p, err)
+			exit(exitUsage)
 		}
 	}
+	if mode == "" {
+		logger.Debug("pipreqs mode", "mode", "default")
+	} else {
+		logger.Debug("pipreqs mode", "mode", mode)
+	}
 
-	var updatedCount uint64
-	var errorCount uint64
-	sem := make(chan struct{}, concurrency)
-	var wg sync.WaitGroup
+	roots := flag.Args()
+	if fromFile != "" && len(roots) > 0 {
+		fmt.Fprintln(os.Stderr, "--from-file cannot be combined with paths")
+		exit(exitUsage)
+	}
+	for _, root := range roots {
+		if info, err := os.Stat(root); err != nil {
+			fmt.Fprintln(os.Stderr, "invalid path:", err)
+			exit(exitUsage)
+		} else if !info.IsDir() {
+			fmt.Fprintln(os.Stderr, "invalid path:", root, "is not a directory")
+			exit(exitUsage)
+		}
+	}
+	if backupDir != "" {
+		if backupDir, err = filepath.Abs(backupDir); err != nil {
+			fmt.Fprintln(os.Stderr, "error:", err)
+			exit(exitFailure)
+		}
+	}
 
-	// Create context for cancellation and coordination
-	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
+	if concurrency < 1 {
+		fmt.Fprintln(os.Stderr, "invalid --concurrency:", concurrency, "(must be >= 1)")
+		exit(exitUsage)
+	}
+	if limit := runtime.NumCPU() * concurrencyPerCPU; concurrency > limit {
+		logger.Warn("concurrency exceeds the per-CPU guideline; pipreqs runs may contend for CPU and memory", "concurrency", concurrency, "limit", limit, "per_cpu", concurrencyPerCPU)
+	}
+	logger.Debug("concurrency", "value", concurrency)
 
-	for _, dir := range reqDirs {
-		wg.Add(1)
-		sem <- struct{}{}
-		go func(d string) {
-			defer wg.Done()
-			defer func() { <-sem }()
-
-			// Check if context is cancelled
-			select {
-			case <-ctx.Done():
-				return
-			default:
-			}
+	cfg := pipreqs.Config{
+		Roots: roots,
+		Discover: pipreqs.DiscoverOptions{
+			MaxDepth:         maxDepth,
+			Excludes:         excludes,
+			RespectGitignore: respectGitignore,
+			FollowSymlinks:   followSymlinks,
+			Workers:          discoveryWorkers,
+			Filenames:        filenames,
+		},
+		Update: pipreqs.Options{
+			DryRun:       dryRun,
+			Mode:         mode,
+			Encoding:     encoding,
+			ExtraArgs:    pipreqsArgs,
+			KeepBackups:  keepBackups,
+			NoBackup:     noBackup,
+			BackupSuffix: backupSuffix,
+			BackupDir:    backupDir,
+			FollowLinks:  !noFollowSymlinks,
+
+			RequirePython: requirePython,
+			ScanNotebooks: scanNotebooks,
+
+			PreserveHeader:  preserveHeader,
+			Merge:           merge,
+			PreserveVCS:     preserveVCS || (merge && !flagSet("preserve-vcs")),
+			Normalize:       normalize,
+			Validate:        validate,
+			MaxRemovedPct:   maxRemovedPct,
+			SkipDirty:       skipDirty,
+			Force:           force,
+			Diff:            showDiff,
+			ChangeDetection: changeDetection,
+			HashAlgorithm:   hashAlgorithm,
+			Pipreqs:         pipreqsPath,
+			Python:          python,
+			PreferVenv:      preferVenv,
+			Timeout:         timeout,
+			Retries:         retries,
+			RetryDelay:      retryDelay,
+			PreHook:         preHook,
+			PostHook:        postHook,
+		},
+		Concurrency:  concurrency,
+		ChangedSince: changedSince,
+		Logger:       logger,
+		FailFast:     failFast,
+		MaxErrors:    maxErrors,
+	}
+	if !noDefaultExcludes {
+		cfg.Discover.SkipNames = pipreqs.DefaultExcludeDirs
+	}
+	if verbose && (preHook != "" || postHook != "") {
+		// stream hook output; failures carry it either way
+		cfg.Update.HookOutput = &lockedWriter{w: logDest}
+	}
+	if stateFile != "" && command == "" {
+		if cfg.State, err = pipreqs.LoadState(stateFile); err != nil {
+			fmt.Fprintln(os.Stderr, "invalid --state-file:", err)
+			exit(exitUsage)
+		}
+		logger.Debug("loaded state", "path", stateFile, "entries", cfg.State.Len())
+	}
+	if fromFile != "" {
+		names := []string(filenames)
+		if len(names) == 0 {
+			names = []string{pipreqs.DefaultFilename}
+		}
+		if cfg.Targets, err = loadTargets(fromFile, names); err != nil {
+			fmt.Fprintln(os.Stderr, "invalid --from-file:", err)
+			exit(exitUsage)
+		}
+	}
 
-			changed, err := updateRequirements(d, dryRun)
+	// runs that write hold a lock so that overlapping invocations cannot
+	// race on the same files; exit releases it, also after a signal
+	if (command == "" || command == "clean" || command == "restore") && !dryRun {
+		lockRoots := roots
+		if len(lockRoots) == 0 {
+			lockRoots = []string{"."}
+		}
+		paths, err := lockPaths(lockFile, lockRoots)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "error:", err)
+			exit(exitFailure)
+		}
+		for _, p := range paths {
+			l, err := acquireLock(ctx, p, lockWait)
 			if err != nil {
-				// Don't print error output during progress display to avoid scrolling
-				// Errors will be shown in final summary
-				atomic.AddUint64(&errorCount, 1)
-			} else {
-				if changed {
-					atomic.AddUint64(&updatedCount, 1)
-				}
+				fmt.Fprintln(os.Stderr, "error:", err)
+				exit(exitFailure)
 			}
-		}(dir)
+			logger.Debug("acquired lock", "path", p)
+			exitHooks = append(exitHooks, l.release)
+		}
 	}
-	wg.Wait()
 
-	// Cancel context to stop progress display
-	cancel()
+	switch command {
+	case "clean", "restore":
+		if len(roots) != 1 {
+			fmt.Fprintln(os.Stderr, command, "takes a single path")
+			exit(exitUsage)
+		}
+		root := roots[0]
+		if cfg.Update.Root, err = filepath.Abs(root); err != nil {
+			fmt.Fprintln(os.Stderr, "error:", err)
+			exit(exitFailure)
+		}
+		backupRoot := root
+		if backupDir != "" {
+			backupRoot = cfg.Update.BackupRoot()
+		}
+		if command == "clean" {
+			exit(runClean(logger, backupRoot, cfg.Discover, backupSuffix, dryRun))
+		}
+		exit(runRestore(logger, backupRoot, cfg.Discover, cfg.Update, force))
+	case "doctor":
+		exit(runDoctor(ctx, os.Stdout, cfg.Update))
+	case "list":
+		exit(runList(cfg, jsonOut))
+	}
 
-	fmt.Println("processed:", len(reqDirs), "updated:", atomic.LoadUint64(&updatedCount), "errors:", atomic.LoadUint64(&errorCount))
-}
+	if deadline > 0 {
+		var cancelDeadline context.CancelFunc
+		ctx, cancelDeadline = context.WithTimeout(ctx, deadline)
+		defer cancelDeadline()
+	}
 
-func findRequirementsDirs(root string, maxDepth int) ([]string, error) {
-	var matched []string
-	rootAbs, err := filepath.Abs(root)
-	if err != nil {
-		return nil, err
+	// --otel traces the run: a root span here and one per directory
+	// through StartTarget; without it the span does nothing
+	runSpan := trace.SpanFromContext(ctx)
+	if otelEnabled {
+		tctx, stopTracing, err := startTracing(ctx, logger)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "invalid --otel configuration:", err)
+			exit(exitUsage)
+		}
+		exitHooks = append(exitHooks, stopTracing)
+		ctx, runSpan = tracer.Start(tctx, "quick_pipreqs.run")
+		// the span must end before the exporter shuts down
+		exitHooks = append(exitHooks, func() { runSpan.End() })
+		cfg.StartTarget = traceTarget
+	}
+
+	// early check for pipreqs availability (dry-run needs it too)
+	bin, versionArgs := cfg.Update.PipreqsCommand("--version")
+	resolved, pipreqsVersion, err := probe(ctx, bin, versionArgs)
+	if err != nil && autoInstall && pipreqsPath == "" {
+		// an explicit --pipreqs path is not something pip can fix
+		logger.Warn("pipreqs unavailable; installing it", "err", err, "command", installHint(cfg.Update))
+		var installOut io.Writer
+		if verbose {
+			installOut = logDest
+		}
+		if err := installPipreqs(ctx, cfg.Update, installOut); err != nil {
+			fmt.Fprintln(os.Stderr, "error: installing pipreqs failed:", err)
+			exit(exitFailure)
+		}
+		resolved, pipreqsVersion, err = probe(ctx, bin, versionArgs)
+	}
+	if err != nil && resolved == "" {
+		switch {
+		case python != "":
+			fmt.Fprintln(os.Stderr, "invalid --python:", err)
+			exit(exitUsage)
+		case pipreqsPath != "":
+			fmt.Fprintln(os.Stderr, "invalid --pipreqs:", err)
+			exit(exitUsage)
+		}
+		fmt.Fprintln(os.Stderr, "pipreqs not found in PATH:", err)
+		fmt.Fprintf(os.Stderr, "install it with: %s, or pass --auto-install (see %s doctor)\n", installHint(cfg.Update), os.Args[0])
+		exit(exitFailure)
 	}
-	info, err := os.Stat(rootAbs)
 	if err != nil {
-		return nil, err
-	}
-	if !info.IsDir() {
-		return nil, errors.New("path is not a directory: " + rootAbs)
-	}
-
-	err = filepath.WalkDir(rootAbs, func(path string, d fs.DirEntry, walkErr error) error {
-		if walkErr != nil {
-			return walkErr
-		}
-		// depth limit
-		if maxDepth >= 0 {
-			rel, _ := filepath.Rel(rootAbs, path)
-			if rel != "." {
-				depth := strings.Count(rel, string(os.PathSeparator))
-				if depth > maxDepth {
-					if d.IsDir() {
-						return fs.SkipDir
-					}
-					return nil
+		fmt.Fprintln(os.Stderr, "error:", err)
+		exit(exitFailure)
+	}
+	logger.Info("pipreqs version", "version", pipreqsVersion)
+	cfg.Update.PipreqsVersion = pipreqsVersion
+	if cfg.State != nil {
+		if noCache {
+			cfg.Cache = pipreqs.NewCache(pipreqsVersion)
+		} else if cfg.Cache, err = pipreqs.LoadCache(cachePath(stateFile), pipreqsVersion); err != nil {
+			logger.Warn("ignoring unreadable cache", "path", cachePath(stateFile), "err", err)
+			cfg.Cache = pipreqs.NewCache(pipreqsVersion)
+		}
+		logger.Debug("loaded cache", "path", cachePath(stateFile), "entries", cfg.Cache.Len())
+	}
+
+	// the printer goroutine in Run is the only writer while workers run;
+	// without --json, logOut is stdout, where the summary goes too
+	color := useColor(colorMode, logOut)
+	var progress *progressLine
+	// interactive runs ask on stdin, one directory at a time so that
+	// prompts never interleave; quitting stops the run without counting as
+	// an interrupt. The next directory can reach its prompt while the
+	// previous result is still being logged, so output is held by one of
+	// them at a time.
+	runCtx := ctx
+	var output sync.Mutex
+	if interactive {
+		var quit context.CancelFunc
+		runCtx, quit = context.WithCancel(ctx)
+		defer quit()
+		p := &prompter{in: bufio.NewReader(os.Stdin), out: os.Stdout, color: color, quit: quit}
+		cfg.Update.Confirm = func(r pipreqs.Result) bool {
+			output.Lock()
+			defer output.Unlock()
+			return p.confirm(r)
+		}
+		cfg.Concurrency = 1
+	}
+	if showProgress && !interactive && format == formatText && isTerminal(os.Stdout) && isTerminal(os.Stderr) {
+		progress = &progressLine{w: os.Stderr}
+		cfg.OnStart = func(targets []pipreqs.Target) {
+			progress.total = len(targets)
+			progress.draw()
+		}
+	}
+	cfg.OnResult = func(r pipreqs.Result) {
+		output.Lock()
+		defer output.Unlock()
+		if progress != nil {
+			progress.clear()
+		}
+		logResult(logger, r, dryRun)
+		if showDiff && !jsonOut && !interactive {
+			// the prompt already showed it
+			writeDiff(logOut, r, color)
+		}
+		if progress != nil {
+			progress.add(r)
+		}
+	}
+	// the manifest and cache are saved after every run; dry runs leave
+	// them alone
+	saveState := func() {
+		if cfg.State == nil || dryRun {
+			return
+		}
+		if err := cfg.State.Save(stateFile); err != nil {
+			logger.Warn("saving state failed", "path", stateFile, "err", err)
+		}
+		if err := cfg.Cache.Save(cachePath(stateFile)); err != nil {
+			logger.Warn("saving cache failed", "path", cachePath(stateFile), "err", err)
+		}
+	}
+	// with --error-log, the full output of each failure goes to the log and
+	// the console only gets one line per failure
+	saveErrorLog := func(s pipreqs.Summary) {
+		if errorLogFile == nil {
+			return
+		}
+		if err := writeErrorLog(errorLogFile, s, time.Now().Add(-s.Elapsed)); err != nil {
+			logger.Warn("writing error log failed", "path", errorLog, "err", err)
+		}
+	}
+	if watch {
+		err := pipreqs.Watch(ctx, cfg, pipreqs.WatchOptions{
+			Debounce: watchDebounce,
+			Rescan:   watchRescan,
+			OnRun: func(s pipreqs.Summary) {
+				updatedLabel := "updated:"
+				if dryRun {
+					updatedLabel = "would update:"
 				}
-			}
+				writeTotals(os.Stdout, s, updatedLabel, color)
+				writeErrors(os.Stdout, s, color, errorLogFile != nil)
+				writeCategories(os.Stdout, s)
+				saveState()
+				saveErrorLog(s)
+			},
+		})
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "error:", err)
+			exit(exitFailure)
 		}
-		// no exclusions
-		if !d.IsDir() && strings.EqualFold(d.Name(), "requirements.txt") {
-			matched = append(matched, filepath.Dir(path))
+		exit(0)
+	}
+	// --git-stash sets uncommitted changes aside for the run; they are put
+	// back once the run (and any --git-commit) is done, or on an early exit
+	popStashes := func() bool { return true }
+	if gitStash {
+		dirs := roots
+		switch {
+		case cfg.Targets != nil:
+			dirs = nil
+			for _, t := range cfg.Targets {
+				dirs = append(dirs, t.Dir)
+			}
+		case len(dirs) == 0:
+			dirs = []string{"."}
 		}
-		return nil
-	})
+		popStashes = stashChanges(logger, dirs)
+		exitHooks = append(exitHooks, func() { popStashes() })
+	}
+	summary, err := pipreqs.Run(runCtx, cfg)
+	if progress != nil {
+		progress.finish()
+	}
 	if err != nil {
-		return nil, err
+		fmt.Fprintln(os.Stderr, "error:", err)
+		exit(exitFailure)
 	}
-	// de-duplicate
-	seen := make(map[string]struct{}, len(matched))
-	out := make([]string, 0, len(matched))
-	for _, dir := range matched {
-		if _, ok := seen[dir]; ok {
-			continue
+
+	saveState()
+	saveErrorLog(summary)
+	recordRun(runSpan, summary, dryRun)
+	if metricsFile != "" {
+		var buf bytes.Buffer
+		err := writeMetrics(&buf, summary, time.Now())
+		if err == nil {
+			// a scrape must never see a half-written file
+			err = pipreqs.WriteFileAtomic(metricsFile, buf.Bytes(), 0o644)
+		}
+		if err != nil {
+			logger.Warn("writing metrics failed", "path", metricsFile, "err", err)
 		}
-		seen[dir] = struct{}{}
-		out = append(out, dir)
 	}
-	return out, nil
-}
 
-func updateRequirements(dir string, dryRun bool) (bool, error) {
-	reqPath := filepath.Join(dir, "requirements.txt")
-	backupPath := reqPath + ".bak"
+	// --aggregate merges the resulting files into one and --detect-conflicts
+	// only compares them; either failing fails the run, but only after the
+	// summary is printed
+	conflictsFailed := false
+	switch {
+	case aggregate != "":
+		conflicts, err := writeAggregate(logger, summary, aggregate, dryRun)
+		if err != nil {
+			logger.Error("writing aggregate requirements failed", "path", aggregate, "err", err)
+			conflictsFailed = true
+		}
+		summary.Conflicts = conflicts
+	case detectConflicts:
+		conflicts, err := pipreqs.FindConflicts(summary)
+		if err != nil {
+			logger.Error("detecting conflicting pins failed", "err", err)
+			conflictsFailed = true
+		}
+		summary.Conflicts = conflicts
+	}
+	for _, c := range summary.Conflicts {
+		logger.Warn("conflicting version pins", "package", c.Name, "files", len(c.Pins))
+	}
+	if failOnConflict && len(summary.Conflicts) > 0 {
+		conflictsFailed = true
+	}
 
-	if dryRun {
-		// Don't print dry-run details during progress display to avoid scrolling
-		return false, nil
+	// remember whether the run was cut short before releasing the context
+	interrupted := ctx.Err()
+	cancel()
+	if errors.Is(interrupted, context.DeadlineExceeded) {
+		logger.Warn("deadline exceeded", "deadline", deadline.String(), "skipped", summary.Skipped)
 	}
 
-	// move current requirements.txt to .bak (overwrite any existing .bak)
-	var preHash string
-	preExists := false
-	if _, err := os.Stat(reqPath); err == nil {
-		preExists = true
-		if h, err := fileHash(reqPath); err == nil {
-			preHash = h
+	if github {
+		// annotations are read from stdout, unless it carries another format
+		writeAnnotations(logOut, summary, dryRun, os.Getenv("GITHUB_WORKSPACE"))
+	}
+	switch format {
+	case formatJSON:
+		if err := writeJSONSummary(os.Stdout, summary, dryRun, showStats); err != nil {
+			fmt.Fprintln(os.Stderr, "error:", err)
+			exit(exitFailure)
+		}
+	case formatMarkdown:
+		writeMarkdownSummary(os.Stdout, summary, dryRun)
+	case formatCSV:
+		if err := writeCSVSummary(os.Stdout, summary); err != nil {
+			fmt.Fprintln(os.Stderr, "error:", err)
+			exit(exitFailure)
+		}
+	case formatText:
+		if github && quiet {
+			// the annotations stand in for the summary
+			break
+		}
+		updatedLabel := "updated:"
+		switch {
+		case check:
+			updatedLabel = "stale:"
+		case dryRun:
+			updatedLabel = "would update:"
+		}
+		writeTotals(os.Stdout, summary, updatedLabel, color)
+		writeElapsed(os.Stdout, summary)
+		writeCache(os.Stdout, summary)
+		if showStats {
+			writeStats(os.Stdout, summary)
 		}
-		// remove old backup if present to mimic a clean move
-		_ = os.Remove(backupPath)
-		if err := os.Rename(reqPath, backupPath); err != nil {
-			return false, err
+		writeAborted(os.Stdout, summary, color)
+		if check {
+			writeStale(os.Stdout, summary, color)
 		}
+		if verbose {
+			writeSkipped(os.Stdout, summary, color)
+		}
+		writeErrors(os.Stdout, summary, color, errorLogFile != nil)
+		writeCategories(os.Stdout, summary)
+		writeConflicts(os.Stdout, summary, color)
 	}
 
-	args := []string{"."}
-	if out, err := runCmd("pipreqs", args, dir); err != nil {
-		return false, fmt.Errorf("pipreqs failed: %w\n%s", err, string(out))
-	}
-	// check post state
-	postExists := false
-	postHash := ""
-	if _, err := os.Stat(reqPath); err == nil {
-		postExists = true
-		if h, err := fileHash(reqPath); err == nil {
-			postHash = h
+	if gitCommit {
+		switch {
+		case summary.Errors > 0, interrupted != nil:
+			logger.Warn("not committing: the run did not complete successfully")
+		case summary.Updated == 0:
+			logger.Info("nothing to commit")
+		default:
+			repos, err := pipreqs.CommitChanges(summary, commitMessage)
+			if err != nil {
+				fmt.Fprintln(os.Stderr, "error: committing:", err)
+				exit(exitFailure)
+			}
+			for _, repo := range repos {
+				logger.Info("committed updated requirements files", "repo", repo)
+			}
 		}
 	}
-	changed := (!preExists && postExists) || (preExists && postExists && preHash != postHash)
-	return changed, nil
+	if !popStashes() {
+		exit(exitFailure)
+	}
+
+	switch {
+	case summary.Errors > 0, interrupted != nil, conflictsFailed:
+		exit(exitFailure)
+	case exitCode && summary.Updated > 0:
+		exit(exitChanged)
+	}
+	exit(0)
 }
 
-func runCmd(bin string, args []string, workDir string) ([]byte, error) {
-	cmd := exec.Command(bin, args...)
-	cmd.Dir = workDir
-	cmd.Env = os.Environ()
-	return cmd.CombinedOutput()
+// cachePath returns where the pipreqs output cache is kept for the
+// manifest at stateFile: state.json caches in state.cache.json.
+func cachePath(stateFile string) string {
+	ext := filepath.Ext(stateFile)
+	return strings.TrimSuffix(stateFile, ext) + ".cache" + ext
 }
 
-func fileHash(path string) (string, error) {
-	f, err := os.Open(path)
-	if err != nil {
-		return "", err
+// useColor reports whether output to f should be colored under mode:
+// "always", "never", or "auto", which colors terminals unless NO_COLOR is
+// set.
+func useColor(mode string, f *os.File) bool {
+	switch mode {
+	case "always":
+		return true
+	case "never":
+		return false
 	}
-	defer f.Close()
-	h := sha256.New()
-	if _, err := io.Copy(h, f); err != nil {
-		return "", err
+	return os.Getenv("NO_COLOR") == "" && isTerminal(f)
+}
+
+// isTerminal reports whether f is a character device such as a terminal.
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// commands lists the subcommands accepted as the first argument.
+var commands = []string{"clean", "restore", "doctor", "list"}
+
+// splitCommand separates a leading subcommand name from the remaining
+// arguments. The default (regenerate) command is returned as "".
+func splitCommand(args []string) (string, []string) {
+	if len(args) > 0 && slices.Contains(commands, args[0]) {
+		return args[0], args[1:]
 	}
-	return fmt.Sprintf("%x", h.Sum(nil)), nil
+	return "", args
+}
+
+// exitHooks run, most recent first, when exit ends the process.
+var exitHooks []func()
+
+// exit runs the exit hooks and ends the process with code. main calls it
+// instead of os.Exit so that profiles are written on every path.
+func exit(code int) {
+	for i := len(exitHooks) - 1; i >= 0; i-- {
+		exitHooks[i]()
+	}
+	os.Exit(code)
+}
+
+// Process exit codes.
+const (
+	exitFailure = 1 // one or more directories failed
+	exitUsage   = 2 // invalid flags or arguments
+	exitChanged = 3 // with --exit-code: at least one file was (or would be) updated
+)
+
+// concurrencyPerCPU is how many parallel pipreqs runs per CPU --concurrency
+// may ask for before main warns. pipreqs is mostly file I/O, so a few per
+// CPU is reasonable; it is not enforced.
+const concurrencyPerCPU = 4
+
+// Summary formats selected by --format.
+const (
+	formatText     = "text"
+	formatJSON     = "json"
+	formatMarkdown = "markdown"
+	formatCSV      = "csv"
+)
+
+// formats lists the accepted --format values.
+var formats = []string{formatText, formatJSON, formatMarkdown, formatCSV}
+
+// defaultCommitMessage is the --commit-message of --git-commit.
+const defaultCommitMessage = "Regenerate requirements with quick_pipreqs"
+
+// stringList is a repeatable string flag that keeps values in the order given.
+type stringList []string
+
+func (s *stringList) String() string { return strings.Join(*s, ",") }
+
+func (s *stringList) Set(v string) error {
+	*s = append(*s, v)
+	return nil
+}
+
+// flagSet reports whether the named flag was given on the command line.
+func flagSet(name string) bool {
+	set := false
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			set = true
+		}
+	})
+	return set
 }
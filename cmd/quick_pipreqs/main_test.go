@@ -0,0 +1,148 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+// TestMain lets tests run the command itself: with QUICK_PIPREQS_RUN_MAIN
+// set, the test binary acts as quick_pipreqs.
+func TestMain(m *testing.M) {
+	if os.Getenv("QUICK_PIPREQS_RUN_MAIN") != "" {
+		main()
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+// fakePipreqs is a pipreqs stand-in honoring --savepath. It writes
+// $FAKE_PIPREQS_OUTPUT, or fails when $FAKE_PIPREQS_FAIL is set.
+const fakePipreqs = `if [ "$1" = "--version" ]; then echo 0.5.0; exit 0; fi
+out=requirements.txt
+while [ $# -gt 0 ]; do case "$1" in --savepath) out="$2"; shift;; esac; shift; done
+if [ -n "$FAKE_PIPREQS_FAIL" ]; then echo "Traceback: boom" >&2; exit 1; fi
+printf '%s' "$FAKE_PIPREQS_OUTPUT" > "$out"
+`
+
+// currentRequirements is the requirements file runMain starts from.
+const currentRequirements = "flask==3.0.0\n"
+
+// runMain runs quick_pipreqs with args on a directory holding
+// currentRequirements, where pipreqs generates output, or fails when fail
+// is set. It returns the exit status and the requirements file afterwards.
+func runMain(t *testing.T, args []string, output string, fail bool) (int, string) {
+	t.Helper()
+	return runMainIn(t, output, fail, func(dir string) []string {
+		return append(args, "--color", "never", dir)
+	})
+}
+
+// runMainIn is runMain with the whole command line returned by args, which
+// is given the directory quick_pipreqs runs in.
+func runMainIn(t *testing.T, output string, fail bool, args func(dir string) []string) (int, string) {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("uses a shell script as pipreqs")
+	}
+	sh, err := exec.LookPath("sh")
+	if err != nil {
+		t.Skip("sh not available")
+	}
+	bin := t.TempDir()
+	if err := os.WriteFile(filepath.Join(bin, "pipreqs"), []byte("#!"+sh+"\n"+fakePipreqs), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	reqPath := filepath.Join(dir, "requirements.txt")
+	if err := os.WriteFile(reqPath, []byte(currentRequirements), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "app.py"), []byte("import flask\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	cmd := exec.Command(os.Args[0], args(dir)...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "QUICK_PIPREQS_RUN_MAIN=1", "PATH="+bin, "FAKE_PIPREQS_OUTPUT="+output)
+	if fail {
+		cmd.Env = append(cmd.Env, "FAKE_PIPREQS_FAIL=1")
+	}
+	out, err := cmd.CombinedOutput()
+	code := 0
+	var ee *exec.ExitError
+	if errors.As(err, &ee) {
+		code = ee.ExitCode()
+	} else if err != nil {
+		t.Fatal(err)
+	}
+	t.Logf("output:\n%s", out)
+	got, _ := os.ReadFile(reqPath)
+	return code, string(got)
+}
+
+// exitCase is a run of quick_pipreqs and its expected outcome.
+type exitCase struct {
+	name     string
+	args     []string
+	output   string // what pipreqs generates
+	fail     bool
+	wantCode int
+	wantFile string // requirements.txt afterwards
+}
+
+// runExitCases runs each case as a subtest.
+func runExitCases(t *testing.T, tests []exitCase) {
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, got := runMain(t, tt.args, tt.output, tt.fail)
+			if code != tt.wantCode {
+				t.Errorf("exit status = %d, want %d", code, tt.wantCode)
+			}
+			if got != tt.wantFile {
+				t.Errorf("requirements.txt = %q, want %q", got, tt.wantFile)
+			}
+		})
+	}
+}
+
+func TestExitStatus(t *testing.T) {
+	current, updated := currentRequirements, currentRequirements+"requests==2.31.0\n"
+	runExitCases(t, []exitCase{
+		{"unchanged", nil, current, false, 0, current},
+		{"updated", nil, updated, false, 0, updated},
+		{"updated with --exit-code", []string{"--exit-code"}, updated, false, exitChanged, updated},
+		{"error", nil, "", true, exitFailure, current},
+		{"error with --exit-code", []string{"--exit-code"}, "", true, exitFailure, current},
+	})
+}
+
+func TestCheck(t *testing.T) {
+	current, updated := currentRequirements, currentRequirements+"requests==2.31.0\n"
+	runExitCases(t, []exitCase{
+		{"up to date", []string{"--check"}, current, false, 0, current},
+		{"drift", []string{"--check"}, updated, false, exitChanged, current},
+		{"error", []string{"--check"}, "", true, exitFailure, current},
+	})
+}
+
+func TestFromFileInConfig(t *testing.T) {
+	updated := currentRequirements + "requests==2.31.0\n"
+	code, got := runMainIn(t, updated, false, func(dir string) []string {
+		// no path on the command line: the config in the working
+		// directory supplies the directories to process
+		if err := os.WriteFile(filepath.Join(dir, "dirs.txt"), []byte(dir+"\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, ".quick_pipreqs.yaml"), []byte("from-file: dirs.txt\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return []string{"--color", "never"}
+	})
+	if code != 0 || got != updated {
+		t.Errorf("exit status = %d, requirements.txt = %q; want 0 and %q", code, got, updated)
+	}
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"time"
+
+	"github.com/bevelwork/quick_pipreqs/pipreqs"
+	"github.com/bevelwork/quick_pipreqs/version"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracer records the run's spans. It uses the global TracerProvider, which
+// does nothing until startTracing installs one.
+var tracer = otel.Tracer("github.com/bevelwork/quick_pipreqs")
+
+// tracingShutdownTimeout bounds how long exporting the last spans may
+// delay exit.
+const tracingShutdownTimeout = 5 * time.Second
+
+// startTracing installs a global TracerProvider that exports spans over
+// OTLP, configured by the standard OTEL_EXPORTER_OTLP_* variables (gRPC when
+// the protocol is "grpc", HTTP otherwise); OTEL_SERVICE_NAME and
+// OTEL_RESOURCE_ATTRIBUTES describe the service. A TRACEPARENT in the
+// environment makes the run part of the caller's trace. Export problems
+// are logged to logger. The returned stop function flushes the remaining
+// spans.
+func startTracing(ctx context.Context, logger *slog.Logger) (context.Context, func(), error) {
+	protocol := os.Getenv("OTEL_EXPORTER_OTLP_TRACES_PROTOCOL")
+	if protocol == "" {
+		protocol = os.Getenv("OTEL_EXPORTER_OTLP_PROTOCOL")
+	}
+	var exporter *otlptrace.Exporter
+	var err error
+	switch protocol {
+	case "", "http/protobuf":
+		exporter, err = otlptracehttp.New(ctx)
+	case "grpc":
+		exporter, err = otlptracegrpc.New(ctx)
+	default:
+		return ctx, nil, fmt.Errorf("unsupported OTLP protocol %q (must be http/protobuf or grpc)", protocol)
+	}
+	if err != nil {
+		return ctx, nil, err
+	}
+	// the environment comes last so that it overrides the defaults
+	res, err := resource.New(ctx,
+		resource.WithTelemetrySDK(),
+		resource.WithAttributes(
+			attribute.String("service.name", "quick_pipreqs"),
+			attribute.String("service.version", version.Full),
+		),
+		resource.WithFromEnv(),
+	)
+	if err != nil {
+		return ctx, nil, err
+	}
+	otel.SetErrorHandler(otel.ErrorHandlerFunc(func(err error) {
+		logger.Warn("tracing failed", "err", err)
+	}))
+	tp := sdktrace.NewTracerProvider(sdktrace.WithBatcher(exporter), sdktrace.WithResource(res))
+	otel.SetTracerProvider(tp)
+
+	propagator := propagation.TraceContext{}
+	ctx = propagator.Extract(ctx, propagation.MapCarrier{"traceparent": os.Getenv("TRACEPARENT")})
+	return ctx, func() {
+		ctx, cancel := context.WithTimeout(context.Background(), tracingShutdownTimeout)
+		defer cancel()
+		if err := tp.Shutdown(ctx); err != nil {
+			logger.Warn("exporting traces failed", "err", err)
+		}
+	}, nil
+}
+
+// traceTarget is a pipreqs.Config.StartTarget that records a
+// quick_pipreqs.regenerate span per target, a child of the span in ctx.
+func traceTarget(ctx context.Context, t pipreqs.Target) (context.Context, func(pipreqs.Result)) {
+	ctx, span := tracer.Start(ctx, "quick_pipreqs.regenerate", trace.WithAttributes(
+		attribute.String("quick_pipreqs.dir", t.Dir),
+		attribute.String("quick_pipreqs.filename", t.Filename),
+	))
+	return ctx, func(r pipreqs.Result) { endTargetSpan(span, r) }
+}
+
+// endTargetSpan records the outcome of r on span and ends it. Failures are
+// recorded as errors; skips only note their reason.
+func endTargetSpan(span trace.Span, r pipreqs.Result) {
+	span.SetAttributes(
+		attribute.Bool("quick_pipreqs.changed", r.Changed),
+		attribute.Int("quick_pipreqs.attempts", r.Attempts),
+	)
+	if r.Cache != "" {
+		span.SetAttributes(attribute.String("quick_pipreqs.cache", r.Cache))
+	}
+	switch {
+	case r.Skipped():
+		span.SetAttributes(attribute.String("quick_pipreqs.skipped", r.Err.Error()))
+	case r.Err != nil:
+		span.SetAttributes(attribute.String("quick_pipreqs.error.category", r.Category()))
+		span.RecordError(r.Err)
+		span.SetStatus(codes.Error, firstLine(r.Err))
+	}
+	span.End()
+}
+
+// recordRun sets the totals of s on the run's root span, marking it failed
+// when any directory failed.
+func recordRun(span trace.Span, s pipreqs.Summary, dryRun bool) {
+	span.SetAttributes(
+		attribute.Bool("quick_pipreqs.dry_run", dryRun),
+		attribute.Int("quick_pipreqs.processed", s.Processed),
+		attribute.Int("quick_pipreqs.updated", s.Updated),
+		attribute.Int("quick_pipreqs.errors", s.Errors),
+		attribute.Int("quick_pipreqs.skipped", s.Skipped),
+	)
+	if s.Errors > 0 {
+		span.SetStatus(codes.Error, fmt.Sprintf("%d of %d directories failed", s.Errors, s.Processed))
+	}
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/bevelwork/quick_pipreqs/pipreqs"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestTraceTarget(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	prev := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	t.Cleanup(func() { otel.SetTracerProvider(prev) })
+
+	root := t.TempDir()
+	for _, d := range []string{"a", "b"} {
+		if err := os.MkdirAll(filepath.Join(root, d), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		for _, f := range []string{"requirements.txt", "app.py"} {
+			if err := os.WriteFile(filepath.Join(root, d, f), nil, 0o644); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	fake := pipreqs.RunnerFunc(func(ctx context.Context, bin string, args []string, dir string) ([]byte, error) {
+		if filepath.Base(dir) == "b" {
+			return []byte("Traceback: boom\n"), errors.New("exit status 1")
+		}
+		i := slices.Index(args, "--savepath")
+		return nil, os.WriteFile(args[i+1], []byte("requests==2.31.0\n"), 0o644)
+	})
+	ctx, parent := tracer.Start(context.Background(), "run")
+	cfg := pipreqs.Config{Root: root, Discover: pipreqs.DiscoverOptions{MaxDepth: 1}, Update: pipreqs.Options{Runner: fake}, Concurrency: 1, StartTarget: traceTarget}
+	if _, err := pipreqs.Run(ctx, cfg); err != nil {
+		t.Fatal(err)
+	}
+	parent.End()
+
+	spans := map[string]sdktrace.ReadOnlySpan{}
+	for _, s := range recorder.Ended() {
+		if s.Name() != "quick_pipreqs.regenerate" {
+			continue
+		}
+		if s.Parent().SpanID() != parent.SpanContext().SpanID() {
+			t.Errorf("span %v is not a child of the run span", s.Attributes())
+		}
+		for _, a := range s.Attributes() {
+			if a.Key == "quick_pipreqs.dir" {
+				spans[filepath.Base(a.Value.AsString())] = s
+			}
+		}
+	}
+	if len(spans) != 2 {
+		t.Fatalf("got spans for %v, want a and b", spans)
+	}
+	if got := spans["a"]; got.Status().Code == codes.Error || !slices.Contains(got.Attributes(), attribute.Bool("quick_pipreqs.changed", true)) {
+		t.Errorf("a: status %v, attributes %v; want a successful change", got.Status(), got.Attributes())
+	}
+	if got := spans["b"]; got.Status().Code != codes.Error || len(got.Events()) == 0 || got.Events()[0].Name != "exception" {
+		t.Errorf("b: status %v, events %v; want a recorded error", got.Status(), got.Events())
+	}
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/bevelwork/quick_pipreqs/pipreqs"
+)
+
+// progressLine renders a single, repeatedly redrawn status line such as
+// "[42/318] updated=10 errors=1". It is only driven from Run's printer
+// goroutine, so it needs no locking.
+type progressLine struct {
+	w       io.Writer
+	total   int
+	done    int
+	updated int
+	errors  int
+}
+
+// add counts a finished result and redraws the line.
+func (p *progressLine) add(r pipreqs.Result) {
+	p.done++
+	switch {
+	case r.Failed():
+		p.errors++
+	case r.Changed:
+		p.updated++
+	}
+	p.draw()
+}
+
+// draw rewrites the line in place.
+func (p *progressLine) draw() {
+	fmt.Fprintf(p.w, "\r\x1b[K[%d/%d] updated=%d errors=%d", p.done, p.total, p.updated, p.errors)
+}
+
+// clear erases the line so other output can be printed in its place.
+func (p *progressLine) clear() {
+	fmt.Fprint(p.w, "\r\x1b[K")
+}
+
+// finish ends the line, leaving the final counts visible.
+func (p *progressLine) finish() {
+	if p.total > 0 {
+		p.draw()
+		fmt.Fprintln(p.w)
+	}
+}
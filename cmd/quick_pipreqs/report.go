@@ -0,0 +1,668 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bevelwork/quick_pipreqs/pipreqs"
+)
+
+type jsonDirResult struct {
+	Index      int      `json:"index"`
+	Path       string   `json:"path"`
+	Filename   string   `json:"filename"`
+	Command    []string `json:"command,omitempty"`
+	Changed    bool     `json:"changed"`
+	Added      []string `json:"added"`
+	Removed    []string `json:"removed"`
+	BackupPath string   `json:"backup_path,omitempty"`
+	Diff       string   `json:"diff,omitempty"`
+	Error      string   `json:"error,omitempty"`
+	Category   string   `json:"category,omitempty"`
+	Skipped    string   `json:"skipped,omitempty"`
+	Attempts   int      `json:"attempts"`
+	Cache      string   `json:"cache,omitempty"`
+	DurationMS float64  `json:"duration_ms"`
+}
+
+type jsonSummary struct {
+	DryRun      bool            `json:"dry_run"`
+	Processed   int             `json:"processed"`
+	Updated     int             `json:"updated"`
+	Errors      int             `json:"errors"`
+	Categories  map[string]int  `json:"error_categories,omitempty"`
+	Skipped     int             `json:"skipped"`
+	Aborted     bool            `json:"aborted"`
+	AbortedBy   int             `json:"aborted_by,omitempty"`
+	MaxErrors   int             `json:"max_errors,omitempty"`
+	ElapsedMS   float64         `json:"elapsed_ms"`
+	Cache       *jsonCache      `json:"cache,omitempty"`
+	Stats       *jsonStats      `json:"stats,omitempty"`
+	Conflicts   []jsonConflict  `json:"conflicts,omitempty"`
+	Directories []jsonDirResult `json:"directories"`
+}
+
+type jsonConflict struct {
+	Package string    `json:"package"`
+	Pins    []jsonPin `json:"pins"`
+}
+
+type jsonPin struct {
+	Path      string `json:"path"`
+	Specifier string `json:"specifier"`
+}
+
+type jsonCache struct {
+	Hits   int `json:"hits"`
+	Misses int `json:"misses"`
+}
+
+type jsonStats struct {
+	Count int     `json:"count"`
+	P50MS float64 `json:"p50_ms"`
+	P90MS float64 `json:"p90_ms"`
+	P99MS float64 `json:"p99_ms"`
+	MaxMS float64 `json:"max_ms"`
+}
+
+// writeJSONSummary writes s as a single indented JSON object, with duration
+// statistics when stats is set. A dry run also lists the pipreqs command
+// line of each directory, so the output is a plan of the real run.
+func writeJSONSummary(w io.Writer, s pipreqs.Summary, dryRun, stats bool) error {
+	out := jsonSummary{
+		DryRun:      dryRun,
+		Processed:   s.Processed,
+		Updated:     s.Updated,
+		Errors:      s.Errors,
+		Categories:  s.Categories,
+		Skipped:     s.Skipped,
+		Aborted:     s.Aborted,
+		ElapsedMS:   float64(s.Elapsed) / float64(time.Millisecond),
+		Directories: make([]jsonDirResult, 0, len(s.Results)),
+	}
+	if st := durationStats(s); stats && st.count > 0 {
+		ms := func(d time.Duration) float64 { return float64(d) / float64(time.Millisecond) }
+		out.Stats = &jsonStats{Count: st.count, P50MS: ms(st.p50), P90MS: ms(st.p90), P99MS: ms(st.p99), MaxMS: ms(st.max)}
+	}
+	if s.CacheHits+s.CacheMisses > 0 {
+		out.Cache = &jsonCache{Hits: s.CacheHits, Misses: s.CacheMisses}
+	}
+	if s.Aborted {
+		out.AbortedBy, out.MaxErrors = s.AbortedBy+1, s.MaxErrors
+	}
+	for _, c := range s.Conflicts {
+		jc := jsonConflict{Package: c.Name}
+		for _, p := range c.Pins {
+			jc.Pins = append(jc.Pins, jsonPin{Path: p.Path, Specifier: p.Specifier})
+		}
+		out.Conflicts = append(out.Conflicts, jc)
+	}
+	for _, r := range s.Results {
+		jr := jsonDirResult{
+			Index:      r.Index + 1,
+			Path:       r.Dir,
+			Filename:   r.Filename,
+			Changed:    r.Changed,
+			Added:      nonNil(r.Added),
+			Removed:    nonNil(r.Removed),
+			BackupPath: r.BackupPath,
+			Diff:       r.Diff,
+			Attempts:   r.Attempts,
+			Cache:      r.Cache,
+			DurationMS: float64(r.Duration) / float64(time.Millisecond),
+		}
+		if dryRun {
+			jr.Command = r.Command
+		}
+		switch {
+		case r.Skipped():
+			jr.Skipped = r.Err.Error()
+		case r.Err != nil:
+			jr.Error = r.Err.Error()
+			jr.Category = r.Category()
+		}
+		out.Directories = append(out.Directories, jr)
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(out)
+}
+
+type jsonTarget struct {
+	Path     string `json:"path"`
+	Dir      string `json:"dir"`
+	Filename string `json:"filename"`
+}
+
+// writeJSONTargets writes targets as an indented JSON array.
+func writeJSONTargets(w io.Writer, targets []pipreqs.Target) error {
+	out := make([]jsonTarget, 0, len(targets))
+	for _, t := range targets {
+		out = append(out, jsonTarget{Path: t.Path(), Dir: t.Dir, Filename: t.Filename})
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(out)
+}
+
+// nonNil returns s, or an empty slice if s is nil, so it encodes as [].
+func nonNil(s []string) []string {
+	if s == nil {
+		return []string{}
+	}
+	return s
+}
+
+// logResult logs the outcome of r: a failure at warn level, anything else
+// at debug level with the packages it added and removed.
+func logResult(logger *slog.Logger, r pipreqs.Result, dryRun bool) {
+	attrs := []any{
+		"index", r.Index + 1,
+		"path", r.Path(),
+		"duration", r.Duration.Round(time.Millisecond).String(),
+	}
+	if r.Attempts > 1 {
+		attrs = append(attrs, "attempts", r.Attempts)
+	}
+	if r.LinkTarget != "" {
+		logger.Warn("requirements file is a symlink; regenerating the file it points to", "path", r.Path(), "target", r.LinkTarget)
+	}
+	switch {
+	case r.Skipped():
+		logger.Debug("skipped", append(attrs, "reason", r.Err.Error())...)
+		return
+	case r.Failed():
+		msg, _, _ := strings.Cut(r.Err.Error(), "\n")
+		logger.Warn("failed", append(attrs, "err", msg)...)
+		return
+	}
+	status := "unchanged"
+	switch {
+	case r.Changed && dryRun:
+		status = "would update"
+	case r.Changed:
+		status = "updated"
+	}
+	if len(r.Added) > 0 {
+		attrs = append(attrs, "added", strings.Join(r.Added, ","))
+	}
+	if len(r.Removed) > 0 {
+		attrs = append(attrs, "removed", strings.Join(r.Removed, ","))
+	}
+	logger.Debug(status, attrs...)
+}
+
+// ANSI escapes used to color diffs and summaries.
+const (
+	ansiReset  = "\x1b[0m"
+	ansiBold   = "\x1b[1m"
+	ansiRed    = "\x1b[31m"
+	ansiGreen  = "\x1b[32m"
+	ansiYellow = "\x1b[33m"
+	ansiCyan   = "\x1b[36m"
+)
+
+// paint wraps s in the ANSI code when color is set.
+func paint(s, code string, color bool) string {
+	if !color {
+		return s
+	}
+	return code + s + ansiReset
+}
+
+// writeTotals prints the one-line summary of s, with updatedLabel for the
+// updated count. Non-zero counts of updated, failed, and skipped files are
+// colored green, red, and yellow when color is set.
+func writeTotals(w io.Writer, s pipreqs.Summary, updatedLabel string, color bool) {
+	count := func(label string, n int, code string) string {
+		field := fmt.Sprintf("%s %d", label, n)
+		if n == 0 {
+			return field
+		}
+		return paint(field, code, color)
+	}
+	fields := []string{
+		fmt.Sprintf("processed: %d", s.Processed),
+		count(updatedLabel, s.Updated, ansiGreen),
+		count("errors:", s.Errors, ansiRed),
+	}
+	if s.Skipped > 0 {
+		fields = append(fields, count("skipped:", s.Skipped, ansiYellow))
+	}
+	fmt.Fprintln(w, strings.Join(fields, " "))
+}
+
+// writeElapsed prints how long the run took and which file took longest.
+func writeElapsed(w io.Writer, s pipreqs.Summary) {
+	line := "elapsed: " + s.Elapsed.Round(time.Millisecond).String()
+	if r, ok := s.Slowest(); ok {
+		line += fmt.Sprintf(" slowest: [%d] %s (%s)", r.Index+1, r.Path(), r.Duration.Round(time.Millisecond))
+	}
+	fmt.Fprintln(w, line)
+}
+
+// writeCache prints the cache hit and miss counts of a run that used the
+// cache.
+func writeCache(w io.Writer, s pipreqs.Summary) {
+	if s.CacheHits+s.CacheMisses == 0 {
+		return
+	}
+	fmt.Fprintf(w, "cache: hits: %d misses: %d\n", s.CacheHits, s.CacheMisses)
+}
+
+// timingStats summarizes the per-file durations of a run.
+type timingStats struct {
+	count              int
+	p50, p90, p99, max time.Duration
+}
+
+// durationStats computes nearest-rank percentiles of the durations of the
+// files that were processed; skipped ones took no time and are left out.
+func durationStats(s pipreqs.Summary) timingStats {
+	var durations []time.Duration
+	for _, r := range s.Results {
+		if !r.Skipped() {
+			durations = append(durations, r.Duration)
+		}
+	}
+	if len(durations) == 0 {
+		return timingStats{}
+	}
+	slices.Sort(durations)
+	rank := func(p int) time.Duration {
+		// the smallest duration at or above p percent of the others
+		return durations[(p*len(durations)+99)/100-1]
+	}
+	return timingStats{
+		count: len(durations),
+		p50:   rank(50),
+		p90:   rank(90),
+		p99:   rank(99),
+		max:   durations[len(durations)-1],
+	}
+}
+
+// writeStats prints the p50, p90, and p99 percentiles and the maximum of
+// the per-file durations.
+func writeStats(w io.Writer, s pipreqs.Summary) {
+	st := durationStats(s)
+	if st.count == 0 {
+		return
+	}
+	ms := func(d time.Duration) time.Duration { return d.Round(time.Millisecond) }
+	fmt.Fprintf(w, "durations (%d files): p50 %s p90 %s p99 %s max %s\n", st.count, ms(st.p50), ms(st.p90), ms(st.p99), ms(st.max))
+}
+
+// writeDiff prints r's unified diff, if any, coloring removed, added, and
+// hunk header lines when color is set.
+func writeDiff(w io.Writer, r pipreqs.Result, color bool) {
+	if r.Diff == "" {
+		return
+	}
+	if !color {
+		fmt.Fprint(w, r.Diff)
+		return
+	}
+	for _, line := range strings.Split(strings.TrimSuffix(r.Diff, "\n"), "\n") {
+		code := ""
+		switch {
+		case strings.HasPrefix(line, "--- "), strings.HasPrefix(line, "+++ "):
+			code = ansiBold
+		case strings.HasPrefix(line, "@@"):
+			code = ansiCyan
+		case strings.HasPrefix(line, "-"):
+			code = ansiRed
+		case strings.HasPrefix(line, "+"):
+			code = ansiGreen
+		}
+		if code == "" {
+			fmt.Fprintln(w, line)
+		} else {
+			fmt.Fprintln(w, code+line+ansiReset)
+		}
+	}
+}
+
+// writeAborted explains why a run stopped early, naming the failure that
+// reached the error limit.
+func writeAborted(w io.Writer, s pipreqs.Summary, color bool) {
+	if !s.Aborted {
+		return
+	}
+	r := s.Results[s.AbortedBy]
+	msg, _, _ := strings.Cut(r.Err.Error(), "\n")
+	reason := "the first error"
+	if s.MaxErrors > 1 {
+		reason = fmt.Sprintf("reaching the error limit (%d)", s.MaxErrors)
+	}
+	fmt.Fprintln(w, paint(fmt.Sprintf("aborted after %s: [%d] %s: %s", reason, r.Index+1, r.Path(), msg), ansiRed, color))
+}
+
+// writeStale lists the directories whose requirements file is out of date.
+func writeStale(w io.Writer, s pipreqs.Summary, color bool) {
+	if s.Updated == 0 {
+		return
+	}
+	fmt.Fprintln(w, "stale:")
+	for _, r := range s.Results {
+		if !r.Failed() && r.Changed {
+			fmt.Fprintf(w, "  [%d] %s\n", r.Index+1, paint(r.Path(), ansiGreen, color))
+		}
+	}
+}
+
+// writeSkipped lists directories that were skipped and why.
+func writeSkipped(w io.Writer, s pipreqs.Summary, color bool) {
+	if s.Skipped == 0 {
+		return
+	}
+	fmt.Fprintln(w, "skipped:")
+	for _, r := range s.Results {
+		if r.Skipped() {
+			fmt.Fprintf(w, "  [%d] %s: %v\n", r.Index+1, paint(r.Path(), ansiYellow, color), r.Err)
+		}
+	}
+}
+
+// writeCategories prints how many failures fell into each category, in
+// the order of pipreqs.Categories.
+func writeCategories(w io.Writer, s pipreqs.Summary) {
+	if s.Errors == 0 {
+		return
+	}
+	var fields []string
+	for _, c := range pipreqs.Categories {
+		if n := s.Categories[c]; n > 0 {
+			fields = append(fields, fmt.Sprintf("%s: %d", c, n))
+		}
+	}
+	fmt.Fprintln(w, "errors by category:", strings.Join(fields, " "))
+}
+
+// writeConflicts lists the packages pinned differently across requirements
+// files, each with the specifier every file gives it.
+func writeConflicts(w io.Writer, s pipreqs.Summary, color bool) {
+	if len(s.Conflicts) == 0 {
+		return
+	}
+	fmt.Fprintln(w, "conflicting pins:")
+	for _, c := range s.Conflicts {
+		fmt.Fprintf(w, "  %s: %s\n", paint(c.Name, ansiYellow, color), pinList(c))
+	}
+}
+
+// pinList formats the pins of c as "path spec, path spec".
+func pinList(c pipreqs.Conflict) string {
+	pins := make([]string, len(c.Pins))
+	for i, p := range c.Pins {
+		pins[i] = p.Path + " " + p.Specifier
+	}
+	return strings.Join(pins, ", ")
+}
+
+// writeErrors prints each failed directory with its error, including any
+// captured pipreqs output, indented under an "errors:" heading in discovery
+// order; when brief is set, only the first line of each error is printed,
+// next to the path. Permission failures follow under a heading of their
+// own, one line each, so their permissions can be fixed together. It prints
+// nothing when the run had no failures.
+func writeErrors(w io.Writer, s pipreqs.Summary, color, brief bool) {
+	if s.Errors > s.PermissionErrors {
+		fmt.Fprintln(w, "errors:")
+		for _, r := range s.Results {
+			if !r.Failed() || r.PermissionDenied() {
+				continue
+			}
+			if brief {
+				fmt.Fprintf(w, "  [%d] %s: %s\n", r.Index+1, paint(r.Path(), ansiRed, color), firstLine(r.Err))
+				continue
+			}
+			fmt.Fprintf(w, "  [%d] %s:\n", r.Index+1, paint(r.Path(), ansiRed, color))
+			for _, line := range strings.Split(strings.TrimRight(r.Err.Error(), "\n"), "\n") {
+				fmt.Fprintf(w, "    %s\n", line)
+			}
+		}
+	}
+	if s.PermissionErrors > 0 {
+		fmt.Fprintf(w, "permission denied (%d); check ownership and write access:\n", s.PermissionErrors)
+		for _, r := range s.Results {
+			if r.PermissionDenied() {
+				fmt.Fprintf(w, "  [%d] %s: %v\n", r.Index+1, paint(r.Path(), ansiRed, color), r.Err)
+			}
+		}
+	}
+}
+
+// writeErrorLog appends the full error of every failure in s, including the
+// captured pipreqs output, to w under a header naming the time the run
+// started.
+func writeErrorLog(w io.Writer, s pipreqs.Summary, start time.Time) error {
+	if s.Errors == 0 {
+		return nil
+	}
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "=== quick_pipreqs run at %s: %d of %d failed ===\n", start.Format(time.RFC3339), s.Errors, s.Processed)
+	for _, r := range s.Results {
+		if !r.Failed() {
+			continue
+		}
+		fmt.Fprintf(&sb, "[%d] %s (%s)\n", r.Index+1, r.Path(), r.Category())
+		sb.WriteString(strings.TrimRight(r.Err.Error(), "\n") + "\n\n")
+	}
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+// writeMarkdownSummary writes s as a GitHub-flavored Markdown summary: a
+// line of totals and a table with one row per directory in processing
+// order, ready to paste into a pull request description.
+func writeMarkdownSummary(w io.Writer, s pipreqs.Summary, dryRun bool) {
+	updated := "updated"
+	if dryRun {
+		updated = "would update"
+	}
+	fmt.Fprintf(w, "**quick_pipreqs**: processed %d, %s %d, errors %d, skipped %d\n\n", s.Processed, updated, s.Updated, s.Errors, s.Skipped)
+	fmt.Fprintln(w, "| # | Path | Changed | Packages | Duration |")
+	fmt.Fprintln(w, "| ---: | --- | --- | --- | ---: |")
+	for _, r := range s.Results {
+		changed := "no"
+		switch {
+		case r.Skipped():
+			// ErrSkip already names itself
+			changed = firstLine(r.Err)
+		case r.Err != nil:
+			changed = "**failed**: " + firstLine(r.Err)
+		case r.Changed:
+			changed = "yes"
+		}
+		fmt.Fprintf(w, "| %d | `%s` | %s | %s | %s |\n", r.Index+1, markdownCell(r.Path()), markdownCell(changed), markdownCell(packageChanges(r)), r.Duration.Round(time.Millisecond))
+	}
+	if len(s.Conflicts) > 0 {
+		fmt.Fprint(w, "\n**Conflicting pins**\n\n")
+		for _, c := range s.Conflicts {
+			var pins []string
+			for _, p := range c.Pins {
+				pins = append(pins, fmt.Sprintf("`%s` in `%s`", p.Specifier, p.Path))
+			}
+			fmt.Fprintf(w, "- `%s`: %s\n", c.Name, strings.Join(pins, ", "))
+		}
+	}
+}
+
+// packageChanges lists r's added and removed packages as "+added -removed".
+func packageChanges(r pipreqs.Result) string {
+	var pkgs []string
+	for _, p := range r.Added {
+		pkgs = append(pkgs, "+"+p)
+	}
+	for _, p := range r.Removed {
+		pkgs = append(pkgs, "-"+p)
+	}
+	return strings.Join(pkgs, " ")
+}
+
+// csvHeader names the columns of writeCSVSummary.
+var csvHeader = []string{"index", "path", "changed", "added", "removed", "skipped", "error", "duration_ms"}
+
+// writeCSVSummary writes one CSV row per directory in processing order,
+// after a header row. Package lists are space-separated, and errors keep
+// their captured pipreqs output.
+func writeCSVSummary(w io.Writer, s pipreqs.Summary) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(csvHeader); err != nil {
+		return err
+	}
+	for _, r := range s.Results {
+		var skipped, errMsg string
+		switch {
+		case r.Skipped():
+			skipped = r.Err.Error()
+		case r.Err != nil:
+			errMsg = r.Err.Error()
+		}
+		row := []string{
+			strconv.Itoa(r.Index + 1),
+			r.Path(),
+			strconv.FormatBool(r.Changed),
+			strings.Join(r.Added, " "),
+			strings.Join(r.Removed, " "),
+			skipped,
+			errMsg,
+			strconv.FormatFloat(float64(r.Duration)/float64(time.Millisecond), 'f', 3, 64),
+		}
+		for i, cell := range row {
+			row[i] = csvCell(cell)
+		}
+		if err := cw.Write(row); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// csvCell keeps spreadsheets from reading s as a formula, by prefixing a
+// quote when it starts with "=", "+", "@", a tab or carriage return, or a
+// "-" that begins a formula such as "-1+2" or "-=x". Negative numbers and
+// pip options such as "-e git+https://..." are left as they are. Quoting
+// of commas, quotes, and newlines is left to encoding/csv.
+func csvCell(s string) string {
+	if s == "" {
+		return s
+	}
+	if strings.ContainsRune("=+@\t\r", rune(s[0])) || s[0] == '-' && minusFormula(s[1:]) {
+		return "'" + s
+	}
+	return s
+}
+
+// minusFormula reports whether rest, what follows a leading "-", makes the
+// cell a formula: it starts with an operator or parenthesis, or with a
+// digit without being a number.
+func minusFormula(rest string) bool {
+	if rest == "" {
+		return false
+	}
+	switch c := rest[0]; {
+	case strings.ContainsRune("=+-@(", rune(c)):
+		return true
+	case c >= '0' && c <= '9' || c == '.':
+		_, err := strconv.ParseFloat(rest, 64)
+		return err != nil
+	}
+	return false
+}
+
+// firstLine returns the first line of err's message.
+func firstLine(err error) string {
+	line, _, _ := strings.Cut(err.Error(), "\n")
+	return line
+}
+
+// markdownCell escapes s for use in a Markdown table cell.
+func markdownCell(s string) string {
+	return strings.ReplaceAll(s, "|", `\|`)
+}
+
+// writeAnnotations prints a GitHub Actions workflow command for each
+// failed directory (::error) and each updated one (::notice), so results
+// show up as annotations in the Actions UI. Files below workspace, the
+// GITHUB_WORKSPACE checkout, are named relative to it so that the
+// annotations link to them.
+func writeAnnotations(w io.Writer, s pipreqs.Summary, dryRun bool, workspace string) {
+	updated := "updated"
+	if dryRun {
+		updated = "would update"
+	}
+	for _, r := range s.Results {
+		file := annotationFile(r.Path(), workspace)
+		switch {
+		case r.Failed():
+			fmt.Fprintf(w, "::error file=%s,title=%s::%s\n", escapeProperty(file), escapeProperty("quick_pipreqs: "+r.Category()), escapeData(r.Err.Error()))
+		case r.Err == nil && r.Changed:
+			msg := updated + " " + r.Filename
+			if pkgs := packageChanges(r); pkgs != "" {
+				msg += ": " + pkgs
+			}
+			fmt.Fprintf(w, "::notice file=%s,title=quick_pipreqs::%s\n", escapeProperty(file), escapeData(msg))
+		}
+	}
+}
+
+// annotationFile returns path relative to workspace when it lies below it,
+// and path unchanged otherwise.
+func annotationFile(path, workspace string) string {
+	if workspace == "" {
+		return path
+	}
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return path
+	}
+	rel, err := filepath.Rel(workspace, abs)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return path
+	}
+	return filepath.ToSlash(rel)
+}
+
+// escapeData escapes the message of a workflow command.
+func escapeData(s string) string {
+	return strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A").Replace(strings.TrimRight(s, "\n"))
+}
+
+// escapeProperty escapes a property value of a workflow command.
+func escapeProperty(s string) string {
+	return strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A", ":", "%3A", ",", "%2C").Replace(s)
+}
+
+// writeMetrics writes s in the Prometheus text exposition format, for the
+// node_exporter textfile collector, as gauges describing the run that
+// finished at end. The collector rejects samples with timestamps, so the
+// time of the run is a gauge of its own.
+func writeMetrics(w io.Writer, s pipreqs.Summary, end time.Time) error {
+	var sb strings.Builder
+	for _, m := range []struct {
+		name, help string
+		value      float64
+	}{
+		{"quick_pipreqs_processed_total", "Requirements files processed by the last run.", float64(s.Processed)},
+		{"quick_pipreqs_updated_total", "Requirements files the last run updated (or, in a dry run, would update).", float64(s.Updated)},
+		{"quick_pipreqs_errors_total", "Requirements files the last run failed to regenerate.", float64(s.Errors)},
+		{"quick_pipreqs_skipped_total", "Requirements files the last run skipped.", float64(s.Skipped)},
+		{"quick_pipreqs_run_duration_seconds", "Wall time of the last run.", s.Elapsed.Seconds()},
+		{"quick_pipreqs_last_run_timestamp_seconds", "Unix time at which the last run finished.", float64(end.UnixMilli()) / 1e3},
+	} {
+		fmt.Fprintf(&sb, "# HELP %s %s\n# TYPE %s gauge\n%s %s\n", m.name, m.help, m.name, m.name, strconv.FormatFloat(m.value, 'f', -1, 64))
+	}
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
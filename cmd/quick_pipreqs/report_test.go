@@ -0,0 +1,340 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/bevelwork/quick_pipreqs/pipreqs"
+)
+
+func TestWriteTotals(t *testing.T) {
+	s := pipreqs.Summary{Processed: 5, Updated: 2, Errors: 0, Skipped: 1}
+	tests := []struct {
+		color bool
+		want  string
+	}{
+		{false, "processed: 5 updated: 2 errors: 0 skipped: 1\n"},
+		{true, "processed: 5 " + ansiGreen + "updated: 2" + ansiReset + " errors: 0 " + ansiYellow + "skipped: 1" + ansiReset + "\n"},
+	}
+	for _, tt := range tests {
+		var buf bytes.Buffer
+		writeTotals(&buf, s, "updated:", tt.color)
+		if buf.String() != tt.want {
+			t.Errorf("color=%v: got %q, want %q", tt.color, buf.String(), tt.want)
+		}
+	}
+}
+
+func TestUseColor(t *testing.T) {
+	f, err := os.CreateTemp(t.TempDir(), "out")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	t.Setenv("NO_COLOR", "1")
+	if !useColor("always", f) || useColor("never", f) || useColor("auto", f) {
+		t.Error("always must color, never and auto (redirected, NO_COLOR) must not")
+	}
+}
+
+func TestDurationStats(t *testing.T) {
+	var results []pipreqs.Result
+	for i := 1; i <= 10; i++ {
+		results = append(results, pipreqs.Result{Index: i - 1, Duration: time.Duration(i) * time.Second})
+	}
+	results = append(results, pipreqs.Result{Index: 10, Err: pipreqs.ErrSkip})
+	got := durationStats(pipreqs.Summarize(results))
+	want := timingStats{count: 10, p50: 5 * time.Second, p90: 9 * time.Second, p99: 10 * time.Second, max: 10 * time.Second}
+	if got != want {
+		t.Errorf("durationStats = %+v, want %+v", got, want)
+	}
+	if got := durationStats(pipreqs.Summary{}); got.count != 0 {
+		t.Errorf("empty summary: %+v", got)
+	}
+}
+
+func TestWriteErrorsGroupsPermissionErrors(t *testing.T) {
+	denied := fmt.Errorf("%w: open a/.requirements.txt.1.tmp: permission denied", pipreqs.ErrPermission)
+	s := pipreqs.Summarize([]pipreqs.Result{
+		{Index: 0, Dir: "a", Filename: "requirements.txt", Err: denied},
+		{Index: 1, Dir: "b", Filename: "requirements.txt", Err: errors.New("pipreqs failed: exit status 1\nTraceback")},
+		{Index: 2, Dir: "c", Filename: "requirements.txt", Err: denied},
+	})
+	if s.Errors != 3 || s.PermissionErrors != 2 {
+		t.Fatalf("summary = %+v, want 3 errors, 2 of them permission errors", s)
+	}
+	var buf bytes.Buffer
+	writeErrors(&buf, s, false, false)
+	want := "errors:\n" +
+		"  [2] b/requirements.txt:\n" +
+		"    pipreqs failed: exit status 1\n" +
+		"    Traceback\n" +
+		"permission denied (2); check ownership and write access:\n" +
+		"  [1] a/requirements.txt: " + denied.Error() + "\n" +
+		"  [3] c/requirements.txt: " + denied.Error() + "\n"
+	if buf.String() != want {
+		t.Errorf("got:\n%s\nwant:\n%s", buf.String(), want)
+	}
+}
+
+func TestWriteErrorLog(t *testing.T) {
+	s := pipreqs.Summarize([]pipreqs.Result{
+		{Index: 0, Dir: "a", Filename: "requirements.txt"},
+		{Index: 1, Dir: "b", Filename: "requirements.txt", Err: errors.New("pipreqs failed: exit status 1\nTraceback")},
+	})
+	var brief bytes.Buffer
+	writeErrors(&brief, s, false, true)
+	if want := "errors:\n  [2] b/requirements.txt: pipreqs failed: exit status 1\n"; brief.String() != want {
+		t.Errorf("brief errors = %q, want %q", brief.String(), want)
+	}
+
+	var buf bytes.Buffer
+	start := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	if err := writeErrorLog(&buf, s, start); err != nil {
+		t.Fatal(err)
+	}
+	want := "=== quick_pipreqs run at 2024-05-01T12:00:00Z: 1 of 2 failed ===\n" +
+		"[2] b/requirements.txt (other)\n" +
+		"pipreqs failed: exit status 1\n" +
+		"Traceback\n\n"
+	if buf.String() != want {
+		t.Errorf("got:\n%s\nwant:\n%s", buf.String(), want)
+	}
+}
+
+func TestWriteCategories(t *testing.T) {
+	var buf bytes.Buffer
+	writeCategories(&buf, pipreqs.Summary{})
+	if buf.Len() != 0 {
+		t.Errorf("a run without failures printed %q", buf.String())
+	}
+	writeCategories(&buf, pipreqs.Summary{Errors: 3, Categories: map[string]int{
+		pipreqs.CategoryTimeout:       1,
+		pipreqs.CategoryPipreqsFailed: 2,
+	}})
+	if want := "errors by category: pipreqs-nonzero: 2 timeout: 1\n"; buf.String() != want {
+		t.Errorf("got %q, want %q", buf.String(), want)
+	}
+}
+
+func TestWriteJSONSummary(t *testing.T) {
+	s := pipreqs.Summarize([]pipreqs.Result{
+		{Index: 0, Dir: "a", Filename: "requirements.txt", Changed: true, Added: []string{"requests"}, Removed: []string{"flask"}, BackupPath: "a/requirements.txt.bak", Attempts: 1, Duration: 12 * time.Millisecond, Command: []string{"pipreqs", ".", "--mode", "gt"}},
+		{Index: 1, Dir: "b", Filename: "requirements.txt", Attempts: 1, Duration: 3 * time.Millisecond},
+		{Index: 2, Dir: "c", Filename: "requirements.txt", Attempts: 2, Err: fmt.Errorf("%w: exit status 1\nTraceback", pipreqs.ErrPipreqsFailed)},
+		{Index: 3, Dir: "d", Filename: "requirements.txt", Err: fmt.Errorf("%w: no .py files", pipreqs.ErrSkip)},
+	})
+	s.Elapsed = 20 * time.Millisecond
+	var buf bytes.Buffer
+	if err := writeJSONSummary(&buf, s, false, false); err != nil {
+		t.Fatal(err)
+	}
+	want := `{
+  "dry_run": false,
+  "processed": 4,
+  "updated": 1,
+  "errors": 1,
+  "error_categories": {
+    "pipreqs-nonzero": 1
+  },
+  "skipped": 1,
+  "aborted": false,
+  "elapsed_ms": 20,
+  "directories": [
+    {
+      "index": 1,
+      "path": "a",
+      "filename": "requirements.txt",
+      "changed": true,
+      "added": [
+        "requests"
+      ],
+      "removed": [
+        "flask"
+      ],
+      "backup_path": "a/requirements.txt.bak",
+      "attempts": 1,
+      "duration_ms": 12
+    },
+    {
+      "index": 2,
+      "path": "b",
+      "filename": "requirements.txt",
+      "changed": false,
+      "added": [],
+      "removed": [],
+      "attempts": 1,
+      "duration_ms": 3
+    },
+    {
+      "index": 3,
+      "path": "c",
+      "filename": "requirements.txt",
+      "changed": false,
+      "added": [],
+      "removed": [],
+      "error": "pipreqs failed: exit status 1\nTraceback",
+      "category": "pipreqs-nonzero",
+      "attempts": 2,
+      "duration_ms": 0
+    },
+    {
+      "index": 4,
+      "path": "d",
+      "filename": "requirements.txt",
+      "changed": false,
+      "added": [],
+      "removed": [],
+      "skipped": "skipped: no .py files",
+      "attempts": 0,
+      "duration_ms": 0
+    }
+  ]
+}
+`
+	if buf.String() != want {
+		t.Errorf("got:\n%s\nwant:\n%s", buf.String(), want)
+	}
+
+	// a dry run is a plan: each directory carries its pipreqs command line
+	buf.Reset()
+	if err := writeJSONSummary(&buf, s, true, false); err != nil {
+		t.Fatal(err)
+	}
+	var plan jsonSummary
+	if err := json.Unmarshal(buf.Bytes(), &plan); err != nil {
+		t.Fatal(err)
+	}
+	if !plan.DryRun || !slices.Equal(plan.Directories[0].Command, []string{"pipreqs", ".", "--mode", "gt"}) {
+		t.Errorf("dry run = %t, command = %q; want the pipreqs command line", plan.DryRun, plan.Directories[0].Command)
+	}
+}
+
+func TestWriteConflicts(t *testing.T) {
+	s := pipreqs.Summary{Conflicts: []pipreqs.Conflict{{Name: "requests", Pins: []pipreqs.Pin{
+		{Path: "a/requirements.txt", Specifier: "==2.31.0"},
+		{Path: "b/requirements.txt", Specifier: ">=2,<2.30"},
+	}}}}
+	var buf bytes.Buffer
+	writeConflicts(&buf, s, false)
+	want := "conflicting pins:\n  requests: a/requirements.txt ==2.31.0, b/requirements.txt >=2,<2.30\n"
+	if buf.String() != want {
+		t.Errorf("got %q, want %q", buf.String(), want)
+	}
+
+	buf.Reset()
+	writeConflicts(&buf, pipreqs.Summary{}, false)
+	if buf.Len() != 0 {
+		t.Errorf("no conflicts: got %q, want nothing", buf.String())
+	}
+}
+
+func TestWriteMarkdownSummary(t *testing.T) {
+	s := pipreqs.Summarize([]pipreqs.Result{
+		{Index: 0, Dir: "a", Filename: "requirements.txt", Changed: true, Added: []string{"requests"}, Removed: []string{"flask"}, Duration: 12 * time.Millisecond},
+		{Index: 1, Dir: "b|x", Filename: "requirements.txt", Duration: 3 * time.Millisecond},
+		{Index: 2, Dir: "c", Filename: "requirements.txt", Err: errors.New("pipreqs failed: exit status 1\nTraceback | boom")},
+		{Index: 3, Dir: "d", Filename: "requirements.txt", Err: fmt.Errorf("%w: no .py files", pipreqs.ErrSkip)},
+	})
+	var buf bytes.Buffer
+	writeMarkdownSummary(&buf, s, true)
+	want := "**quick_pipreqs**: processed 4, would update 1, errors 1, skipped 1\n\n" +
+		"| # | Path | Changed | Packages | Duration |\n" +
+		"| ---: | --- | --- | --- | ---: |\n" +
+		"| 1 | `a/requirements.txt` | yes | +requests -flask | 12ms |\n" +
+		"| 2 | `b\\|x/requirements.txt` | no |  | 3ms |\n" +
+		"| 3 | `c/requirements.txt` | **failed**: pipreqs failed: exit status 1 |  | 0s |\n" +
+		"| 4 | `d/requirements.txt` | skipped: no .py files |  | 0s |\n"
+	if buf.String() != want {
+		t.Errorf("got:\n%s\nwant:\n%s", buf.String(), want)
+	}
+}
+
+func TestWriteCSVSummary(t *testing.T) {
+	s := pipreqs.Summarize([]pipreqs.Result{
+		{Index: 0, Dir: "a,b", Filename: "requirements.txt", Changed: true, Added: []string{"requests", "flask"}, Duration: 1500 * time.Microsecond},
+		{Index: 1, Dir: "c", Filename: "requirements.txt", Err: errors.New("=HYPERLINK(\"x\")\nTraceback")},
+		{Index: 2, Dir: "d", Filename: "requirements.txt", Err: fmt.Errorf("%w: no .py files", pipreqs.ErrSkip)},
+	})
+	var buf bytes.Buffer
+	if err := writeCSVSummary(&buf, s); err != nil {
+		t.Fatal(err)
+	}
+	want := "index,path,changed,added,removed,skipped,error,duration_ms\n" +
+		"1,\"a,b/requirements.txt\",true,requests flask,,,,1.500\n" +
+		"2,c/requirements.txt,false,,,,\"'=HYPERLINK(\"\"x\"\")\nTraceback\",0.000\n" +
+		"3,d/requirements.txt,false,,,skipped: no .py files,,0.000\n"
+	if buf.String() != want {
+		t.Errorf("got:\n%s\nwant:\n%s", buf.String(), want)
+	}
+}
+
+func TestCSVCell(t *testing.T) {
+	tests := map[string]string{
+		"":                             "",
+		"requests":                     "requests",
+		"=SUM(A1)":                     "'=SUM(A1)",
+		"+1":                           "'+1",
+		"@cmd":                         "'@cmd",
+		"\tx":                          "'\tx",
+		"\rx":                          "'\rx",
+		"-e git+https://example.com/x": "-e git+https://example.com/x",
+		"-r base.txt":                  "-r base.txt",
+		"-5":                           "-5",
+		"-1.25":                        "-1.25",
+		"-":                            "-",
+		"-1+2":                         "'-1+2",
+		"-=1":                          "'-=1",
+		"-(1)":                         "'-(1)",
+		"--hash=sha256:abc":            "'--hash=sha256:abc",
+	}
+	for in, want := range tests {
+		if got := csvCell(in); got != want {
+			t.Errorf("csvCell(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestWriteAnnotations(t *testing.T) {
+	workspace := t.TempDir()
+	s := pipreqs.Summarize([]pipreqs.Result{
+		{Index: 0, Dir: filepath.Join(workspace, "a"), Filename: "requirements.txt", Changed: true, Added: []string{"requests"}},
+		{Index: 1, Dir: "/elsewhere/b,c", Filename: "requirements.txt", Err: fmt.Errorf("%w: exit status 1\nstderr:\n100%% broken", pipreqs.ErrPipreqsFailed)},
+		{Index: 2, Dir: filepath.Join(workspace, "d"), Filename: "requirements.txt"},
+	})
+	var buf bytes.Buffer
+	writeAnnotations(&buf, s, false, workspace)
+	want := "::notice file=a/requirements.txt,title=quick_pipreqs::updated requirements.txt: +requests\n" +
+		"::error file=/elsewhere/b%2Cc/requirements.txt,title=quick_pipreqs%3A pipreqs-nonzero::pipreqs failed: exit status 1%0Astderr:%0A100%25 broken\n"
+	if buf.String() != want {
+		t.Errorf("got:\n%s\nwant:\n%s", buf.String(), want)
+	}
+}
+
+func TestWriteMetrics(t *testing.T) {
+	s := pipreqs.Summary{Processed: 4, Updated: 2, Errors: 1, Skipped: 1, Elapsed: 1500 * time.Millisecond}
+	var buf bytes.Buffer
+	if err := writeMetrics(&buf, s, time.UnixMilli(1700000000250)); err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range []string{
+		"# TYPE quick_pipreqs_processed_total gauge\nquick_pipreqs_processed_total 4\n",
+		"quick_pipreqs_updated_total 2\n",
+		"quick_pipreqs_errors_total 1\n",
+		"quick_pipreqs_skipped_total 1\n",
+		"quick_pipreqs_run_duration_seconds 1.5\n",
+		"quick_pipreqs_last_run_timestamp_seconds 1700000000.25\n",
+	} {
+		if !strings.Contains(buf.String(), line) {
+			t.Errorf("metrics lack %q:\n%s", line, buf.String())
+		}
+	}
+}
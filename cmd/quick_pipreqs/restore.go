@@ -0,0 +1,63 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+
+	"github.com/bevelwork/quick_pipreqs/pipreqs"
+)
+
+// runRestore implements the restore subcommand: every backup found below
+// root is moved back over the requirements file it was taken from. A file
+// edited since the run that produced the backup is left alone unless force
+// is set. It returns the process exit code.
+func runRestore(logger *slog.Logger, root string, discover pipreqs.DiscoverOptions, opts pipreqs.Options, force bool) int {
+	backups, err := pipreqs.FindBackups(root, discover, opts.Suffix())
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "error:", err)
+		return exitFailure
+	}
+	logger.Info("found backup files", "count", len(backups))
+
+	restored, skipped, failed := 0, 0, 0
+	for _, b := range backups {
+		target := opts.OriginalPath(b)
+		if edited, err := pipreqs.EditedSinceBackup(target, b); err != nil {
+			fmt.Fprintln(os.Stderr, "error:", err)
+			failed++
+			continue
+		} else if edited && !force {
+			logger.Warn("file was modified after its backup was taken; use --force to overwrite", "path", target)
+			skipped++
+			continue
+		}
+		if opts.DryRun {
+			fmt.Println("would restore:", target)
+			restored++
+			continue
+		}
+		if real, err := filepath.EvalSymlinks(target); err == nil {
+			// put the content back behind the link, as the run wrote it
+			target = real
+		}
+		if err := pipreqs.MoveFile(b, target); err != nil {
+			fmt.Fprintln(os.Stderr, "error:", err)
+			failed++
+			continue
+		}
+		logger.Debug("restored", "path", target)
+		restored++
+	}
+
+	label := "restored:"
+	if opts.DryRun {
+		label = "would restore:"
+	}
+	fmt.Println("found:", len(backups), label, restored, "skipped:", skipped, "errors:", failed)
+	if failed > 0 || skipped > 0 {
+		return exitFailure
+	}
+	return 0
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/bevelwork/quick_pipreqs/pipreqs"
+)
+
+// stubPipreqs returns a Runner that writes content as the generated
+// requirements file, to the --savepath target if one is given.
+func stubPipreqs(content string) pipreqs.Runner {
+	return pipreqs.RunnerFunc(func(ctx context.Context, bin string, args []string, workDir string) ([]byte, error) {
+		target := filepath.Join(workDir, "requirements.txt")
+		if i := slices.Index(args, "--savepath"); i >= 0 && i+1 < len(args) {
+			target = args[i+1]
+		}
+		return nil, os.WriteFile(target, []byte(content), 0o644)
+	})
+}
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRunRestore(t *testing.T) {
+	tests := []struct {
+		name        string
+		edit        bool
+		force       bool
+		wantCode    int
+		wantContent string
+	}{
+		{"untouched", false, false, 0, "flask==3.0.0\n"},
+		{"edited", true, false, exitFailure, "requests==2.31.0\nnumpy\n"},
+		{"edited force", true, true, 0, "flask==3.0.0\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := t.TempDir()
+			reqPath := filepath.Join(root, "requirements.txt")
+			writeFile(t, reqPath, "flask==3.0.0\n")
+			opts := pipreqs.Options{Root: root, Runner: stubPipreqs("requests==2.31.0\n")}
+			if err := pipreqs.UpdateRequirements(context.Background(), root, opts).Err; err != nil {
+				t.Fatal(err)
+			}
+			if tt.edit {
+				writeFile(t, reqPath, "requests==2.31.0\nnumpy\n")
+				later := time.Now().Add(time.Minute)
+				if err := os.Chtimes(reqPath, later, later); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			logger := slog.New(slog.DiscardHandler)
+			if code := runRestore(logger, root, pipreqs.DiscoverOptions{MaxDepth: -1}, opts, tt.force); code != tt.wantCode {
+				t.Errorf("exit code = %d, want %d", code, tt.wantCode)
+			}
+			if got, err := os.ReadFile(reqPath); err != nil || string(got) != tt.wantContent {
+				t.Errorf("requirements.txt = %q, %v; want %q", got, err, tt.wantContent)
+			}
+		})
+	}
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+
+	"github.com/bevelwork/quick_pipreqs/pipreqs"
+)
+
+// stashChanges stashes the uncommitted changes of the git work trees
+// holding dirs, warning about dirs outside any. If stashing fails, the
+// stashes taken so far are popped again and main exits. The returned
+// function pops every stash, once however often it is called, reporting
+// the ones that could not be restored; it returns false if any could not.
+func stashChanges(logger *slog.Logger, dirs []string) func() bool {
+	var stashes []*pipreqs.Stash
+	popped := false
+	pop := func() bool {
+		if popped {
+			return true
+		}
+		popped = true
+		ok := true
+		for _, st := range stashes {
+			if err := st.Pop(); err != nil {
+				fmt.Fprintln(os.Stderr, "error:", err)
+				ok = false
+				continue
+			}
+			logger.Info("restored stashed changes", "repo", st.Dir)
+		}
+		return ok
+	}
+	for _, dir := range dirs {
+		st, err := pipreqs.GitStash(dir)
+		switch {
+		case errors.Is(err, pipreqs.ErrNotGitRepo):
+			logger.Warn("nothing to stash outside a git repository", "path", dir)
+		case err != nil:
+			fmt.Fprintln(os.Stderr, "error: stashing changes:", err)
+			pop()
+			exit(exitFailure)
+		case st != nil:
+			logger.Info("stashed uncommitted changes", "repo", st.Dir)
+			stashes = append(stashes, st)
+		}
+	}
+	return pop
+}
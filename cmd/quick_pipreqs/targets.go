@@ -0,0 +1,57 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/bevelwork/quick_pipreqs/pipreqs"
+)
+
+// readDirList reads newline-separated directory paths, ignoring blank lines
+// and lines starting with "#".
+func readDirList(r io.Reader) ([]string, error) {
+	var dirs []string
+	sc := bufio.NewScanner(r)
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		dirs = append(dirs, line)
+	}
+	return dirs, sc.Err()
+}
+
+// loadTargets reads the directory list at path ("-" for stdin) and returns a
+// target for each of names in every listed directory. Every directory must
+// exist.
+func loadTargets(path string, names []string) ([]pipreqs.Target, error) {
+	var r io.Reader = os.Stdin
+	if path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		r = f
+	}
+	dirs, err := readDirList(r)
+	if err != nil {
+		return nil, fmt.Errorf("reading %s: %w", path, err)
+	}
+	targets := []pipreqs.Target{}
+	for _, dir := range dirs {
+		if info, err := os.Stat(dir); err != nil {
+			return nil, err
+		} else if !info.IsDir() {
+			return nil, fmt.Errorf("%s is not a directory", dir)
+		}
+		for _, name := range names {
+			targets = append(targets, pipreqs.Target{Dir: dir, Filename: name})
+		}
+	}
+	return targets, nil
+}
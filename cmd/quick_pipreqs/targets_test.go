@@ -0,0 +1,37 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/bevelwork/quick_pipreqs/pipreqs"
+)
+
+func TestLoadTargets(t *testing.T) {
+	dir := t.TempDir()
+	for _, d := range []string{"a", "b"} {
+		if err := os.Mkdir(filepath.Join(dir, d), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	a, b := filepath.Join(dir, "a"), filepath.Join(dir, "b")
+	list := filepath.Join(dir, "dirs.txt")
+	writeFile(t, list, "# changed projects\n"+a+"\n\n  "+b+"  \n")
+
+	got, err := loadTargets(list, []string{"requirements.txt", "dev.txt"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []pipreqs.Target{{Dir: a, Filename: "requirements.txt"}, {Dir: a, Filename: "dev.txt"}, {Dir: b, Filename: "requirements.txt"}, {Dir: b, Filename: "dev.txt"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("targets = %+v, want %+v", got, want)
+	}
+
+	writeFile(t, list, a+"\n"+filepath.Join(dir, "missing")+"\n")
+	if _, err := loadTargets(list, []string{"requirements.txt"}); err == nil || !strings.Contains(err.Error(), "missing") {
+		t.Errorf("err = %v, want one naming the missing directory", err)
+	}
+}
@@ -0,0 +1,145 @@
+package pipreqs
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"maps"
+	"os"
+	"slices"
+	"strings"
+)
+
+// Pin is the version specifier one requirements file gives a package.
+type Pin struct {
+	Path      string // the requirements file
+	Specifier string // e.g. "==2.31.0" or ">=1,<2"
+}
+
+// Conflict is a package that requirements files pin to different version
+// specifiers. Files that name the package without a specifier accept any
+// version and do not conflict.
+type Conflict struct {
+	Name string // canonical name, see canonicalName
+	Pins []Pin  // every file with a specifier for the package, in processing order
+}
+
+// packageEntry is one file's requirement for a package.
+type packageEntry struct {
+	path string
+	req  requirement
+}
+
+// requirementSet is the requirements of several files, by canonical name.
+type requirementSet struct {
+	entries map[string][]packageEntry // in the order the files were read
+	urls    []string                  // VCS/URL install lines, deduplicated
+}
+
+// readRequirementSet parses the requirements file of every result in s that
+// did not fail, in processing order, or in a dry run the content it would
+// get. Files that no longer exist are left out; one that does not parse is
+// an error naming it.
+func readRequirementSet(s Summary) (requirementSet, error) {
+	set := requirementSet{entries: map[string][]packageEntry{}}
+	seenURL := map[string]bool{}
+	for _, r := range s.Results {
+		if r.Failed() {
+			continue
+		}
+		path := r.Path()
+		content := r.Preview
+		if content == nil {
+			var err error
+			content, err = os.ReadFile(path)
+			if errors.Is(err, fs.ErrNotExist) {
+				continue
+			}
+			if err != nil {
+				return requirementSet{}, err
+			}
+		}
+		reqs, err := parseRequirements(content)
+		if err != nil {
+			return requirementSet{}, fmt.Errorf("%s: %w", path, err)
+		}
+		for _, req := range reqs {
+			key := canonicalName(req.Name)
+			set.entries[key] = append(set.entries[key], packageEntry{path: path, req: req})
+		}
+		for _, u := range urlRequirements(content) {
+			if !seenURL[u] {
+				seenURL[u] = true
+				set.urls = append(set.urls, u)
+			}
+		}
+	}
+	return set, nil
+}
+
+// conflicts returns the packages given more than one distinct specifier,
+// sorted by name.
+func (set requirementSet) conflicts() []Conflict {
+	var out []Conflict
+	for _, key := range slices.Sorted(maps.Keys(set.entries)) {
+		var pins []Pin
+		distinct := map[string]bool{}
+		for _, e := range set.entries[key] {
+			if e.req.Specifier != "" {
+				pins = append(pins, Pin{Path: e.path, Specifier: e.req.Specifier})
+				distinct[e.req.Specifier] = true
+			}
+		}
+		if len(distinct) > 1 {
+			out = append(out, Conflict{Name: key, Pins: pins})
+		}
+	}
+	return out
+}
+
+// Aggregate merges the requirements files of every result in s that did
+// not fail, taking a dry run's Preview in place of the file it would
+// change, into one requirements file, returned as its content, with one
+// line per package under its canonical name, sorted. A package keeps the
+// first specifier and marker found in processing order, and the extras of
+// all files. VCS/URL lines are kept once each, replacing the entry for the
+// package they install; pip option lines such as "-r other.txt" are
+// dropped. The packages pinned differently across the files are returned
+// as conflicts.
+func Aggregate(s Summary) ([]byte, []Conflict, error) {
+	set, err := readRequirementSet(s)
+	if err != nil {
+		return nil, nil, err
+	}
+	reqs := make([]requirement, 0, len(set.entries))
+	for key, entries := range set.entries {
+		merged := entries[0].req
+		var extras []string
+		for _, e := range entries {
+			if merged.Specifier == "" && e.req.Specifier != "" {
+				merged = e.req
+			}
+			for _, x := range e.req.Extras {
+				if !slices.ContainsFunc(extras, func(have string) bool { return strings.EqualFold(have, x) }) {
+					extras = append(extras, x)
+				}
+			}
+		}
+		merged.Name = key
+		merged.Extras = extras
+		reqs = append(reqs, merged)
+	}
+	sortRequirements(reqs)
+	return withURLRequirements(formatRequirements(reqs), set.urls), set.conflicts(), nil
+}
+
+// FindConflicts reads the requirements files of every result in s that did
+// not fail, like Aggregate, and returns only the packages they pin to
+// different version specifiers.
+func FindConflicts(s Summary) ([]Conflict, error) {
+	set, err := readRequirementSet(s)
+	if err != nil {
+		return nil, err
+	}
+	return set.conflicts(), nil
+}
@@ -0,0 +1,100 @@
+package pipreqs
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestAggregate(t *testing.T) {
+	root := t.TempDir()
+	makeTree(t, root, "a/requirements.txt", "b/requirements.txt", "c/requirements.txt", "d/requirements.txt")
+	writeFile(t, filepath.Join(root, "a", "requirements.txt"), "requests==2.31.0\nFlask[async]>=2\n")
+	writeFile(t, filepath.Join(root, "b", "requirements.txt"), "requests==2.30.0\nflask[dotenv]\n-r base.txt\ngit+https://example.com/x.git#egg=x\n")
+	writeFile(t, filepath.Join(root, "c", "requirements.txt"), "Requests\nnumpy==1.26.0\nx==1.0\n")
+	writeFile(t, filepath.Join(root, "d", "requirements.txt"), "broken==\n")
+	s := Summarize([]Result{
+		{Index: 0, Dir: filepath.Join(root, "a"), Filename: "requirements.txt"},
+		{Index: 1, Dir: filepath.Join(root, "b"), Filename: "requirements.txt"},
+		{Index: 2, Dir: filepath.Join(root, "c"), Filename: "requirements.txt"},
+		{Index: 3, Dir: filepath.Join(root, "d"), Filename: "requirements.txt", Err: errors.New("pipreqs failed")},
+		{Index: 4, Dir: filepath.Join(root, "gone"), Filename: "requirements.txt"},
+	})
+
+	content, conflicts, err := Aggregate(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "flask[async,dotenv]>=2\nnumpy==1.26.0\nrequests==2.31.0\ngit+https://example.com/x.git#egg=x\n"
+	if string(content) != want {
+		t.Errorf("content = %q, want %q", content, want)
+	}
+	wantConflicts := []Conflict{{Name: "requests", Pins: []Pin{
+		{Path: filepath.Join(root, "a", "requirements.txt"), Specifier: "==2.31.0"},
+		{Path: filepath.Join(root, "b", "requirements.txt"), Specifier: "==2.30.0"},
+	}}}
+	if !reflect.DeepEqual(conflicts, wantConflicts) {
+		t.Errorf("conflicts = %+v, want %+v", conflicts, wantConflicts)
+	}
+
+	if found, err := FindConflicts(s); err != nil || !reflect.DeepEqual(found, wantConflicts) {
+		t.Errorf("FindConflicts = %+v, %v; want %+v", found, err, wantConflicts)
+	}
+
+	// a file that does not parse is an error, unless its directory failed
+	s.Results[3].Err = nil
+	if _, _, err := Aggregate(s); err == nil {
+		t.Error("unparseable file: no error")
+	}
+	if _, err := FindConflicts(s); err == nil {
+		t.Error("FindConflicts, unparseable file: no error")
+	}
+}
+
+func TestFindConflictsSameSpecifier(t *testing.T) {
+	root := t.TempDir()
+	makeTree(t, root, "a/requirements.txt", "b/requirements.txt", "c/requirements.txt")
+	writeFile(t, filepath.Join(root, "a", "requirements.txt"), "python_dateutil==2.9.0\n")
+	writeFile(t, filepath.Join(root, "b", "requirements.txt"), "python-dateutil==2.9.0\n")
+	writeFile(t, filepath.Join(root, "c", "requirements.txt"), "Python.Dateutil\n")
+	var results []Result
+	for i, d := range []string{"a", "b", "c"} {
+		results = append(results, Result{Index: i, Dir: filepath.Join(root, d), Filename: "requirements.txt"})
+	}
+	conflicts, err := FindConflicts(Summarize(results))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(conflicts) != 0 {
+		t.Errorf("conflicts = %+v, want none for one specifier and an unpinned entry", conflicts)
+	}
+}
+
+func TestAggregateDryRun(t *testing.T) {
+	root := t.TempDir()
+	makeTree(t, root, "a/requirements.txt", "b/requirements.txt")
+	writeFile(t, filepath.Join(root, "a", "requirements.txt"), "flask==3.0.0\n")
+	writeFile(t, filepath.Join(root, "b", "requirements.txt"), "requests==2.31.0\n")
+	fake := &fakeRunner{content: "requests==2.31.0\n"}
+	var results []Result
+	for i, d := range []string{"a", "b"} {
+		r := UpdateRequirements(context.Background(), filepath.Join(root, d), Options{Runner: fake, DryRun: true})
+		r.Index = i
+		results = append(results, r)
+	}
+
+	// a would become what b already is; the report must not show flask
+	content, _, err := Aggregate(Summarize(results))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "requests==2.31.0\n"; string(content) != want {
+		t.Errorf("content = %q, want %q", content, want)
+	}
+	if got, _ := os.ReadFile(filepath.Join(root, "a", "requirements.txt")); string(got) != "flask==3.0.0\n" {
+		t.Errorf("dry run changed a/requirements.txt to %q", got)
+	}
+}
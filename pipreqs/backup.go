@@ -0,0 +1,183 @@
+package pipreqs
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// FindBackups returns the backup files below root whose name is one of the
+// requirements file names followed by suffix, using the same walk rules as
+// discovery.
+func FindBackups(root string, opts DiscoverOptions, suffix string) ([]string, error) {
+	var matched []string
+	err := walkTree(root, opts, func(path string, d fs.DirEntry) {
+		for _, name := range opts.names() {
+			if strings.EqualFold(d.Name(), name+suffix) {
+				matched = append(matched, path)
+				return
+			}
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(matched)
+	return matched, nil
+}
+
+// BackupPath returns where the backup of reqPath is kept. Under BackupDir,
+// backups mirror the file's absolute path, so that files below different
+// roots, or outside any root, never share a backup.
+func (o Options) BackupPath(reqPath string) string {
+	if o.BackupDir == "" {
+		return reqPath + o.Suffix()
+	}
+	abs, err := filepath.Abs(reqPath)
+	if err != nil {
+		abs = reqPath
+	}
+	return o.mirror(abs) + o.Suffix()
+}
+
+// OriginalPath is the inverse of BackupPath: it returns the requirements
+// file that backup was taken from.
+func (o Options) OriginalPath(backup string) string {
+	orig := strings.TrimSuffix(backup, o.Suffix())
+	if o.BackupDir == "" {
+		return orig
+	}
+	rel, err := filepath.Rel(o.BackupDir, orig)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(os.PathSeparator)) {
+		return orig
+	}
+	return filepath.VolumeName(o.BackupDir) + string(os.PathSeparator) + rel
+}
+
+// BackupRoot returns the directory holding the backups of the files below
+// Root: Root itself, or its mirror under BackupDir.
+func (o Options) BackupRoot() string {
+	if o.BackupDir == "" {
+		return o.Root
+	}
+	return o.mirror(o.Root)
+}
+
+// mirror returns where the absolute path abs is mirrored under BackupDir.
+func (o Options) mirror(abs string) string {
+	return filepath.Join(o.BackupDir, strings.TrimPrefix(abs, filepath.VolumeName(abs)))
+}
+
+// Suffix returns the backup file suffix, defaulting to ".bak" for backups
+// kept alongside the original.
+func (o Options) Suffix() string {
+	if o.BackupSuffix == "" && o.BackupDir == "" {
+		return ".bak"
+	}
+	return o.BackupSuffix
+}
+
+// stampBackup sets the backup's modification time to that of the freshly
+// generated file. restore treats a requirements file whose mtime no longer
+// matches its backup as manually edited.
+func stampBackup(reqPath, backupPath string) error {
+	info, err := os.Stat(reqPath)
+	if err != nil {
+		return err
+	}
+	return os.Chtimes(backupPath, time.Time{}, info.ModTime())
+}
+
+// copyBackup copies a requirements file to its backup; tests replace it to
+// simulate failing filesystems.
+var copyBackup = copyFile
+
+// writeBackup copies reqPath to backupPath and checks that the backup holds
+// all size bytes of the original before anything replaces it. An incomplete
+// backup is removed.
+func writeBackup(reqPath, backupPath string, size int64) error {
+	if err := copyBackup(reqPath, backupPath); err != nil {
+		_ = os.Remove(backupPath)
+		return fmt.Errorf("writing backup %s: %w", backupPath, err)
+	}
+	info, err := os.Stat(backupPath)
+	if err != nil {
+		return fmt.Errorf("verifying backup %s: %w", backupPath, err)
+	}
+	if info.Size() != size {
+		_ = os.Remove(backupPath)
+		return fmt.Errorf("verifying backup %s: wrote %d of %d bytes", backupPath, info.Size(), size)
+	}
+	return nil
+}
+
+// MoveFile renames src to dst, falling back to copy-and-remove when they
+// are on different filesystems (e.g. a --backup-dir on another mount).
+func MoveFile(src, dst string) error {
+	err := os.Rename(src, dst)
+	if err == nil || !errors.Is(err, syscall.EXDEV) {
+		return err
+	}
+	if err := copyFile(src, dst); err != nil {
+		return err
+	}
+	return os.Remove(src)
+}
+
+// copyFile copies src to dst, replacing dst and keeping src's permissions.
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	info, err := in.Stat()
+	if err != nil {
+		return err
+	}
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, info.Mode().Perm())
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// EditedSinceBackup reports whether target was changed after the run that
+// left backup behind. Identical content is never considered edited; otherwise
+// the modification times stamped by UpdateRequirements must still match.
+func EditedSinceBackup(target, backup string) (bool, error) {
+	tInfo, err := os.Stat(target)
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	bInfo, err := os.Stat(backup)
+	if err != nil {
+		return false, err
+	}
+	th, err := FileHash(target)
+	if err != nil {
+		return false, err
+	}
+	bh, err := FileHash(backup)
+	if err != nil {
+		return false, err
+	}
+	if th == bh {
+		return false, nil
+	}
+	return !tInfo.ModTime().Equal(bInfo.ModTime()), nil
+}
@@ -0,0 +1,112 @@
+package pipreqs
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"syscall"
+	"testing"
+)
+
+func TestFindBackups(t *testing.T) {
+	root := t.TempDir()
+	makeTree(t, root,
+		"requirements.txt.bak",
+		"a/requirements.txt",
+		"a/requirements.txt.bak",
+		"a/requirements.txt.orig",
+		"a/b/c/requirements.txt.bak",
+		".venv/requirements.txt.bak",
+		"skip/requirements.txt.bak",
+	)
+	opts := DiscoverOptions{MaxDepth: 2, Excludes: []string{"skip"}, SkipNames: DefaultExcludeDirs}
+
+	got, err := FindBackups(root, opts, ".bak")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"a/requirements.txt.bak", "requirements.txt.bak"}
+	if rel := relDirs(t, root, got); !reflect.DeepEqual(rel, want) {
+		t.Errorf("backups = %q, want %q", rel, want)
+	}
+}
+
+func TestUpdateRequirementsBackupFailure(t *testing.T) {
+	tests := []struct {
+		name    string
+		copy    func(src, dst string) error
+		wantErr string
+	}{
+		{"copy fails", func(src, dst string) error {
+			return errors.New("input/output error")
+		}, "writing backup"},
+		{"truncated", func(src, dst string) error {
+			return os.WriteFile(dst, []byte("fla"), 0o644)
+		}, "wrote 3 of 13 bytes"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			orig := copyBackup
+			copyBackup = tt.copy
+			defer func() { copyBackup = orig }()
+
+			dir := t.TempDir()
+			reqPath := filepath.Join(dir, "requirements.txt")
+			writeFile(t, reqPath, "flask==3.0.0\n")
+			fake := &fakeRunner{content: "requests==2.31.0\n"}
+			err := UpdateRequirements(context.Background(), dir, Options{Runner: fake}).Err
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("err = %v, want it to mention %q", err, tt.wantErr)
+			}
+			if len(fake.calls) != 0 {
+				t.Error("pipreqs ran without a verified backup")
+			}
+			if got, _ := os.ReadFile(reqPath); string(got) != "flask==3.0.0\n" {
+				t.Errorf("requirements.txt = %q, want original content", got)
+			}
+			if _, err := os.Stat(reqPath + ".bak"); !os.IsNotExist(err) {
+				t.Error("incomplete backup left behind")
+			}
+		})
+	}
+}
+
+func TestUpdateRequirementsPermissionDenied(t *testing.T) {
+	orig := copyBackup
+	copyBackup = func(src, dst string) error {
+		return &fs.PathError{Op: "open", Path: dst, Err: syscall.EACCES}
+	}
+	defer func() { copyBackup = orig }()
+
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "requirements.txt"), "flask==3.0.0\n")
+	res := UpdateRequirements(context.Background(), dir, Options{Runner: &fakeRunner{content: "requests\n"}})
+	if !res.Failed() || !res.PermissionDenied() {
+		t.Errorf("err = %v, want a permission failure", res.Err)
+	}
+	if s := Summarize([]Result{res}); s.Errors != 1 || s.PermissionErrors != 1 {
+		t.Errorf("summary = %+v, want the failure counted as a permission error", s)
+	}
+}
+
+func TestBackupPathRoundTrip(t *testing.T) {
+	root := t.TempDir()
+	outside := t.TempDir()
+	opts := Options{Root: root, BackupDir: t.TempDir(), BackupSuffix: ".bak"}
+	for _, reqPath := range []string{
+		filepath.Join(root, "svc", "requirements.txt"),
+		filepath.Join(outside, "tools", "requirements.txt"),
+	} {
+		backup := opts.BackupPath(reqPath)
+		if !strings.HasPrefix(backup, opts.BackupDir+string(os.PathSeparator)) {
+			t.Errorf("backup of %s = %s, want it under %s", reqPath, backup, opts.BackupDir)
+		}
+		if got := opts.OriginalPath(backup); got != reqPath {
+			t.Errorf("OriginalPath(%s) = %s, want %s", backup, got, reqPath)
+		}
+	}
+}
@@ -0,0 +1,176 @@
+package pipreqs
+
+import (
+	"cmp"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+)
+
+// Result.Cache values.
+const (
+	CacheHit  = "hit"  // pipreqs output was taken from the cache
+	CacheMiss = "miss" // pipreqs ran and its output was cached
+)
+
+// cacheVersion is the cache format written by Cache.Save.
+const cacheVersion = 1
+
+// DefaultCacheEntries is how many outputs a Cache keeps when saved, beyond
+// those used since it was loaded.
+const DefaultCacheEntries = 1000
+
+// Cache maps the SourceHash of a directory to the requirements pipreqs
+// generated for it, before any post-processing, so that unchanged sources
+// never need pipreqs again. The whole cache is tied to one pipreqs version.
+// Save drops the least recently used outputs beyond DefaultCacheEntries.
+// It is safe for concurrent use.
+type Cache struct {
+	mu      sync.Mutex
+	pipreqs string
+	entries map[string]string
+	used    map[string]int64 // generation in which each entry was last used
+	gen     int64            // generation of this run, newer than any loaded
+	limit   int
+}
+
+type cacheFile struct {
+	Version int               `json:"version"`
+	Pipreqs string            `json:"pipreqs"`
+	Entries map[string]string `json:"entries"`
+	Used    map[string]int64  `json:"used,omitempty"`
+}
+
+// NewCache returns an empty cache for output of the given pipreqs version.
+func NewCache(pipreqsVersion string) *Cache {
+	return &Cache{pipreqs: pipreqsVersion, entries: map[string]string{}, used: map[string]int64{}, gen: 1, limit: DefaultCacheEntries}
+}
+
+// LoadCache reads the cache at path. A missing file, or one written for a
+// different pipreqs version, is an empty cache.
+func LoadCache(path, pipreqsVersion string) (*Cache, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return NewCache(pipreqsVersion), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var f cacheFile
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if f.Version != cacheVersion {
+		return nil, fmt.Errorf("%s: unsupported cache version %d", path, f.Version)
+	}
+	c := NewCache(pipreqsVersion)
+	if f.Pipreqs == pipreqsVersion {
+		for k, v := range f.Entries {
+			c.entries[k] = v
+			// entries of files without generations count as oldest
+			c.used[k] = f.Used[k]
+			c.gen = max(c.gen, f.Used[k]+1)
+		}
+	}
+	return c, nil
+}
+
+// Save writes the cache to path, replacing it atomically, after evicting
+// the least recently used entries beyond the cache's limit. Entries used
+// since the cache was loaded are always kept.
+func (c *Cache) Save(path string) error {
+	c.mu.Lock()
+	c.evict()
+	data, err := json.MarshalIndent(cacheFile{Version: cacheVersion, Pipreqs: c.pipreqs, Entries: c.entries, Used: c.used}, "", "  ")
+	c.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	return WriteFileAtomic(path, append(data, '\n'), 0o644)
+}
+
+// Len returns the number of cached outputs.
+func (c *Cache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.entries)
+}
+
+func (c *Cache) get(key string) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	content, ok := c.entries[key]
+	if ok {
+		c.used[key] = c.gen
+	}
+	return []byte(content), ok
+}
+
+func (c *Cache) put(key string, content []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[key] = string(content)
+	c.used[key] = c.gen
+}
+
+// touch marks the entry for key, if any, as used without reading it, for
+// directories whose output is still current and so never looked up.
+func (c *Cache) touch(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.entries[key]; ok {
+		c.used[key] = c.gen
+	}
+}
+
+// evict drops the least recently used entries until at most c.limit are
+// left, keeping those used in this run. c.mu must be held.
+func (c *Cache) evict() {
+	if len(c.entries) <= c.limit {
+		return
+	}
+	keys := make([]string, 0, len(c.entries))
+	for k := range c.entries {
+		keys = append(keys, k)
+	}
+	slices.SortFunc(keys, func(a, b string) int {
+		if c.used[a] != c.used[b] {
+			return cmp.Compare(c.used[a], c.used[b])
+		}
+		return cmp.Compare(a, b)
+	})
+	for _, k := range keys[:len(keys)-c.limit] {
+		if c.used[k] == c.gen {
+			break
+		}
+		delete(c.entries, k)
+		delete(c.used, k)
+	}
+}
+
+// WriteFileAtomic replaces path with data, with permissions perm, through
+// a temporary file in the same directory, so readers never see a partial
+// file.
+func WriteFileAtomic(path string, data []byte, perm fs.FileMode) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), perm); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,103 @@
+package pipreqs
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRunCache(t *testing.T) {
+	root := t.TempDir()
+	makeTree(t, root, "requirements.txt", "app.py")
+	path := filepath.Join(t.TempDir(), "state.cache.json")
+	fake := &fakeRunner{content: "requests==2.31.0\n"}
+	run := func(cache *Cache) Summary {
+		t.Helper()
+		s, err := Run(context.Background(), Config{Root: root, Update: Options{Runner: fake}, Concurrency: 1, Cache: cache})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return s
+	}
+
+	cache := NewCache("0.5.0")
+	if s := run(cache); s.CacheMisses != 1 || s.CacheHits != 0 || len(fake.calls) != 1 {
+		t.Fatalf("first run: summary = %+v, %d pipreqs calls", s, len(fake.calls))
+	}
+	if err := cache.Save(path); err != nil {
+		t.Fatal(err)
+	}
+
+	cache, err := LoadCache(path, "0.5.0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	reqPath := filepath.Join(root, "requirements.txt")
+	if err := os.Remove(reqPath); err != nil {
+		t.Fatal(err)
+	}
+	fake.content = "changed\n"
+	s := run(cache)
+	if s.CacheHits != 1 || s.Results[0].Cache != CacheHit || len(fake.calls) != 1 {
+		t.Errorf("second run: summary = %+v, %d pipreqs calls; want a cache hit", s, len(fake.calls))
+	}
+	if got, _ := os.ReadFile(reqPath); string(got) != "requests==2.31.0\n" {
+		t.Errorf("requirements.txt = %q, want the cached output", got)
+	}
+
+	if cache, err = LoadCache(path, "0.6.0"); err != nil {
+		t.Fatal(err)
+	}
+	if cache.Len() != 0 {
+		t.Errorf("cache for another pipreqs version has %d entries, want 0", cache.Len())
+	}
+}
+
+func TestCacheEviction(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.cache.json")
+	load := func() *Cache {
+		t.Helper()
+		c, err := LoadCache(path, "0.5.0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		c.limit = 2
+		return c
+	}
+	save := func(c *Cache) {
+		t.Helper()
+		if err := c.Save(path); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	c := load()
+	c.put("a", []byte("a\n"))
+	c.put("b", []byte("b\n"))
+	save(c)
+
+	// a is used again and c is new, so b is the least recently used
+	c = load()
+	if _, ok := c.get("a"); !ok {
+		t.Fatal("a missing after reload")
+	}
+	c.put("c", []byte("c\n"))
+	save(c)
+	c = load()
+	for key, want := range map[string]bool{"a": true, "b": false, "c": true} {
+		if _, ok := c.get(key); ok != want {
+			t.Errorf("entry %s kept = %v, want %v", key, ok, want)
+		}
+	}
+
+	// entries used in the run that saves are kept even beyond the limit
+	c = load()
+	for _, key := range []string{"d", "e", "f"} {
+		c.put(key, []byte(key+"\n"))
+	}
+	save(c)
+	if c = load(); c.Len() != 3 {
+		t.Errorf("cache has %d entries, want the 3 used in the last run", c.Len())
+	}
+}
@@ -0,0 +1,66 @@
+package pipreqs
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"os/exec"
+	"strings"
+)
+
+// Failure categories returned by Result.Category.
+const (
+	CategoryPipreqsMissing = "pipreqs-missing"   // pipreqs could not be started or its module is not installed
+	CategoryPipreqsFailed  = "pipreqs-nonzero"   // pipreqs ran and failed
+	CategoryTimeout        = "timeout"           // pipreqs ran longer than Options.Timeout
+	CategoryPermission     = "permission"        // see ErrPermission
+	CategoryValidation     = "validation"        // output rejected by Validate or MaxRemovedPct
+	CategoryHook           = "hook"              // the post-hook failed
+	CategoryCancelled      = "context-cancelled" // the run was interrupted or hit its deadline
+	CategoryOther          = "other"
+)
+
+// Categories lists the failure categories in the order summaries show them.
+var Categories = []string{
+	CategoryPipreqsMissing,
+	CategoryPipreqsFailed,
+	CategoryTimeout,
+	CategoryPermission,
+	CategoryValidation,
+	CategoryHook,
+	CategoryCancelled,
+	CategoryOther,
+}
+
+// Category classifies a failed result into one of Categories, or returns
+// "" if r did not fail.
+func (r Result) Category() string {
+	if !r.Failed() {
+		return ""
+	}
+	return categorize(r.Err)
+}
+
+func categorize(err error) string {
+	var oe *OutputError
+	switch {
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		return CategoryCancelled
+	case errors.Is(err, ErrTimeout):
+		return CategoryTimeout
+	case errors.Is(err, ErrPermission):
+		return CategoryPermission
+	case errors.Is(err, ErrInvalidRequirements), errors.Is(err, ErrTooManyRemoved):
+		return CategoryValidation
+	case errors.Is(err, ErrHookFailed):
+		return CategoryHook
+	case !errors.Is(err, ErrPipreqsFailed):
+		return CategoryOther
+	case errors.Is(err, exec.ErrNotFound), errors.Is(err, fs.ErrNotExist):
+		return CategoryPipreqsMissing
+	case errors.As(err, &oe) && strings.Contains(string(oe.Stderr), "No module named pipreqs"):
+		// --python with an interpreter that lacks the package
+		return CategoryPipreqsMissing
+	}
+	return CategoryPipreqsFailed
+}
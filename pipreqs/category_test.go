@@ -0,0 +1,45 @@
+package pipreqs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os/exec"
+	"testing"
+)
+
+func TestResultCategory(t *testing.T) {
+	ctx := context.Background()
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	exitErr := exec.Command("false").Run()
+	tests := []struct {
+		name string
+		err  error
+		want string
+	}{
+		{"ok", nil, ""},
+		{"skipped", fmt.Errorf("%w: no .py files", ErrSkip), ""},
+		{"missing", pipreqsError(ctx, &exec.Error{Name: "pipreqs", Err: exec.ErrNotFound}, nil), CategoryPipreqsMissing},
+		{"missing module", pipreqsError(ctx, &OutputError{Err: exitErr, Stderr: []byte("/usr/bin/python3: No module named pipreqs\n")}, nil), CategoryPipreqsMissing},
+		{"nonzero", pipreqsError(ctx, &OutputError{Err: exitErr, Stderr: []byte("Traceback\n")}, nil), CategoryPipreqsFailed},
+		{"timeout", pipreqsError(ctx, fmt.Errorf("%w after 1s", ErrTimeout), nil), CategoryTimeout},
+		{"permission", permissionError(fmt.Errorf("writing backup: %w", fs.ErrPermission)), CategoryPermission},
+		{"validation", fmt.Errorf("%w: line 2: bad", ErrInvalidRequirements), CategoryValidation},
+		{"too many removed", fmt.Errorf("%w: 3 of 4", ErrTooManyRemoved), CategoryValidation},
+		{"hook", fmt.Errorf("%w: post-hook \"make\": exit status 2", ErrHookFailed), CategoryHook},
+		{"cancelled", pipreqsError(cancelled, exitErr, nil), CategoryCancelled},
+		{"other", errors.New("pipreqs did not write requirements.txt"), CategoryOther},
+	}
+	for _, tt := range tests {
+		if got := (Result{Err: tt.err}).Category(); got != tt.want {
+			t.Errorf("%s: Category() = %q, want %q (err %v)", tt.name, got, tt.want, tt.err)
+		}
+	}
+
+	s := Summarize([]Result{{Err: tests[4].err}, {Err: tests[4].err}, {Err: tests[5].err}, {}})
+	if s.Categories[CategoryPipreqsFailed] != 2 || s.Categories[CategoryTimeout] != 1 || len(s.Categories) != 2 {
+		t.Errorf("Categories = %v, want 2 pipreqs-nonzero and 1 timeout", s.Categories)
+	}
+}
@@ -0,0 +1,80 @@
+package pipreqs
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"hash"
+	"hash/crc32"
+
+	"github.com/cespare/xxhash/v2"
+)
+
+// Change detection methods for Options.ChangeDetection.
+const (
+	ChangeHash = "hash" // compare hashes of the contents; the default
+	ChangeSize = "size" // compare sizes, then the contents themselves
+)
+
+// Hash algorithms for Options.HashAlgorithm. They only decide whether a file
+// changed; none of them is stored or shown.
+const (
+	HashSHA256 = "sha256" // the default
+	HashXXHash = "xxhash" // XXH64: much faster, not cryptographic
+	HashCRC32  = "crc32"  // IEEE CRC-32: fast, but 32 bits
+)
+
+// ValidHashAlgorithm reports whether algorithm is an accepted
+// Options.HashAlgorithm value. An empty algorithm means HashSHA256.
+func ValidHashAlgorithm(algorithm string) bool {
+	_, err := newHash(algorithm)
+	return err == nil
+}
+
+// newHash returns a new hash for algorithm.
+func newHash(algorithm string) (hash.Hash, error) {
+	switch algorithm {
+	case "", HashSHA256:
+		return sha256.New(), nil
+	case HashXXHash:
+		return xxhash.New(), nil
+	case HashCRC32:
+		return crc32.NewIEEE(), nil
+	}
+	return nil, fmt.Errorf("unknown hash algorithm %q", algorithm)
+}
+
+// ValidChangeDetection reports whether method is an accepted
+// Options.ChangeDetection value. An empty method means ChangeHash.
+func ValidChangeDetection(method string) bool {
+	switch method {
+	case "", ChangeHash, ChangeSize:
+		return true
+	}
+	return false
+}
+
+// contentChanged reports whether a file went from content old to content
+// new under o's change detection method. Contents of different sizes
+// always differ; otherwise ChangeSize compares the bytes instead of hashing
+// them.
+func (o Options) contentChanged(old, new []byte) bool {
+	if len(old) != len(new) {
+		return true
+	}
+	if o.ChangeDetection == ChangeSize {
+		return !bytes.Equal(old, new)
+	}
+	return hashBytes(old, o.HashAlgorithm) != hashBytes(new, o.HashAlgorithm)
+}
+
+// hashBytes returns the hex-encoded hash of data using algorithm; an
+// unknown algorithm falls back to SHA-256.
+func hashBytes(data []byte, algorithm string) string {
+	h, err := newHash(algorithm)
+	if err != nil {
+		h = sha256.New()
+	}
+	h.Write(data)
+	return fmt.Sprintf("%x", h.Sum(nil))
+}
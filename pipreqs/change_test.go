@@ -0,0 +1,95 @@
+package pipreqs
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestChangeDetection(t *testing.T) {
+	tests := []struct {
+		name        string
+		opts        Options
+		wantChanged bool
+	}{
+		{"default", Options{}, false},
+		{"hash", Options{ChangeDetection: ChangeHash}, false},
+		{"size", Options{ChangeDetection: ChangeSize}, false},
+		{"xxhash", Options{HashAlgorithm: HashXXHash}, false},
+		{"crc32", Options{HashAlgorithm: HashCRC32}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			reqPath := filepath.Join(dir, "requirements.txt")
+			writeFile(t, reqPath, "requests==2.31.0\n")
+			past := time.Now().Add(-time.Hour)
+			if err := os.Chtimes(reqPath, past, past); err != nil {
+				t.Fatal(err)
+			}
+
+			opts := tt.opts
+			opts.Runner = &fakeRunner{content: "requests==2.31.0\n"}
+			res := UpdateRequirements(context.Background(), dir, opts)
+			if res.Err != nil {
+				t.Fatal(res.Err)
+			}
+			if res.Changed != tt.wantChanged {
+				t.Errorf("changed = %v, want %v", res.Changed, tt.wantChanged)
+			}
+		})
+	}
+}
+
+func TestChangeDetectionSameSize(t *testing.T) {
+	for _, opts := range []Options{{}, {ChangeDetection: ChangeSize}} {
+		dir := t.TempDir()
+		writeFile(t, filepath.Join(dir, "requirements.txt"), "requests==2.31.0\n")
+		opts.Runner = &fakeRunner{content: "requests==2.32.0\n"}
+		res := UpdateRequirements(context.Background(), dir, opts)
+		if res.Err != nil || !res.Changed {
+			t.Errorf("detection %q: changed = %v, %v; want a same-size edit detected", opts.ChangeDetection, res.Changed, res.Err)
+		}
+	}
+}
+
+func TestValidChangeDetection(t *testing.T) {
+	for _, m := range []string{"", "hash", "size"} {
+		if !ValidChangeDetection(m) {
+			t.Errorf("ValidChangeDetection(%q) = false", m)
+		}
+	}
+	if ValidChangeDetection("mtime") {
+		t.Error(`ValidChangeDetection("mtime") = true`)
+	}
+}
+
+func TestFileHashWith(t *testing.T) {
+	dir := t.TempDir()
+	a, b := filepath.Join(dir, "a"), filepath.Join(dir, "b")
+	writeFile(t, a, "requests==2.31.0\n")
+	writeFile(t, b, "requests==2.32.0\n")
+	for _, alg := range []string{HashSHA256, HashXXHash, HashCRC32} {
+		ha, err := FileHashWith(a, alg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		hb, err := FileHashWith(b, alg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ha == hb {
+			t.Errorf("%s: different files hash to %s", alg, ha)
+		}
+	}
+	if h, _ := FileHash(a); h != "" {
+		if hs, _ := FileHashWith(a, ""); hs != h {
+			t.Errorf("default hash %s, want SHA-256 %s", hs, h)
+		}
+	}
+	if _, err := FileHashWith(a, "md5"); err == nil || ValidHashAlgorithm("md5") {
+		t.Error("md5 accepted")
+	}
+}
@@ -0,0 +1,25 @@
+package pipreqs
+
+import "testing"
+
+func TestUnifiedDiff(t *testing.T) {
+	tests := []struct {
+		name     string
+		old, new string
+		want     string
+	}{
+		{"identical", "flask==3.0.0\n", "flask==3.0.0\n", ""},
+		{"change", "flask==2.0.0\nrequests==2.31.0\nurllib3==2.0.0\n", "flask==3.0.0\nrequests==2.31.0\n",
+			"--- a\n+++ b\n@@ -1,3 +1,2 @@\n-flask==2.0.0\n+flask==3.0.0\n requests==2.31.0\n-urllib3==2.0.0\n"},
+		{"new file", "", "numpy\n", "--- a\n+++ b\n@@ -0,0 +1 @@\n+numpy\n"},
+		{"separate hunks", "1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n", "1\n2x\n3\n4\n5\n6\n7\n8\n9\n10x\n",
+			"--- a\n+++ b\n@@ -1,5 +1,5 @@\n 1\n-2\n+2x\n 3\n 4\n 5\n@@ -7,4 +7,4 @@\n 7\n 8\n 9\n-10\n+10x\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := unifiedDiff("a", "b", []byte(tt.old), []byte(tt.new)); got != tt.want {
+				t.Errorf("diff =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,390 @@
+package pipreqs
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// DiscoverOptions controls how FindRequirementsDirs walks the tree.
+type DiscoverOptions struct {
+	MaxDepth  int      // levels to descend below the root; 0 is the root only, negative (UnlimitedDepth) has no limit
+	Excludes  []string // glob patterns matched against relative directory paths
+	SkipNames []string // directory names skipped at any depth, case-insensitive
+
+	RespectGitignore bool // skip anything matched by .gitignore files
+	FollowSymlinks   bool // descend into symlinked directories, each real directory once
+
+	// Workers is how many directories are read at once. 0 or 1 walks the
+	// tree sequentially, as do RespectGitignore and FollowSymlinks, whose
+	// rules depend on the order directories are visited in.
+	Workers int
+
+	Filenames []string // requirements files to look for; DefaultFilename if empty
+}
+
+// UnlimitedDepth is the DiscoverOptions.MaxDepth that walks the whole tree.
+const UnlimitedDepth = -1
+
+// DefaultFilename is the requirements file pipreqs writes by default.
+const DefaultFilename = "requirements.txt"
+
+// names returns the requirements file names to match.
+func (o DiscoverOptions) names() []string {
+	if len(o.Filenames) == 0 {
+		return []string{DefaultFilename}
+	}
+	return o.Filenames
+}
+
+// Target is one requirements file to regenerate. A directory holding
+// several of the configured file names yields one target per name.
+type Target struct {
+	Dir      string
+	Filename string
+}
+
+// Path returns the requirements file's full path.
+func (t Target) Path() string { return filepath.Join(t.Dir, t.Filename) }
+
+// SortTargets orders targets by directory, then file name.
+func SortTargets(targets []Target) {
+	sort.Slice(targets, func(i, j int) bool {
+		if targets[i].Dir != targets[j].Dir {
+			return targets[i].Dir < targets[j].Dir
+		}
+		return targets[i].Filename < targets[j].Filename
+	})
+}
+
+// DefaultExcludeDirs are directory names skipped during discovery unless
+// --no-default-excludes is given.
+var DefaultExcludeDirs = []string{".git", ".venv", "venv", "__pycache__", "node_modules"}
+
+// FindRequirementsDirs walks root and returns one Target per requirements
+// file found, without duplicates.
+func FindRequirementsDirs(root string, opts DiscoverOptions) ([]Target, error) {
+	var matched []Target
+	err := walkTree(root, opts, func(path string, d fs.DirEntry) {
+		matched = appendTargets(matched, filepath.Dir(path), d.Name(), opts)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return dedupTargets(matched, realTarget), nil
+}
+
+// FindRequirementsDirsFS is FindRequirementsDirs over fsys. Target
+// directories are slash-separated paths within fsys, "." for its root.
+// .gitignore files above fsys are not consulted.
+func FindRequirementsDirsFS(fsys fs.FS, opts DiscoverOptions) ([]Target, error) {
+	var ignore *gitignore
+	if opts.RespectGitignore {
+		ignore = &gitignore{}
+	}
+	var matched []Target
+	err := walkFS(fsys, "", opts, ignore, func(p string, d fs.DirEntry) {
+		matched = appendTargets(matched, path.Dir(p), d.Name(), opts)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return dedupTargets(matched, nil), nil
+}
+
+// appendTargets appends a Target for dir if name is one of the requirements
+// file names in opts.
+func appendTargets(targets []Target, dir, name string, opts DiscoverOptions) []Target {
+	for _, n := range opts.names() {
+		if strings.EqualFold(name, n) {
+			targets = append(targets, Target{Dir: dir, Filename: n})
+		}
+	}
+	return targets
+}
+
+// dedupTargets removes repeated targets, keeping the first occurrence.
+// Targets are compared by key(t), or as they are if key is nil.
+func dedupTargets(targets []Target, key func(Target) Target) []Target {
+	seen := make(map[Target]struct{}, len(targets))
+	out := make([]Target, 0, len(targets))
+	for _, t := range targets {
+		k := t
+		if key != nil {
+			k = key(t)
+		}
+		if _, ok := seen[k]; ok {
+			continue
+		}
+		seen[k] = struct{}{}
+		out = append(out, t)
+	}
+	return out
+}
+
+// realTarget returns t with its directory resolved to an absolute path
+// without symlinks, so that aliases of one directory compare equal. If the
+// directory cannot be resolved, its absolute (or literal) path is used.
+func realTarget(t Target) Target {
+	if real, err := filepath.EvalSymlinks(t.Dir); err == nil {
+		if abs, err := filepath.Abs(real); err == nil {
+			t.Dir = abs
+			return t
+		}
+	}
+	if abs, err := filepath.Abs(t.Dir); err == nil {
+		t.Dir = abs
+	}
+	return t
+}
+
+// walkTree walks root applying the depth limit and exclusions in opts, and
+// calls visit with the absolute path of every file that survives them.
+func walkTree(root string, opts DiscoverOptions, visit func(path string, d fs.DirEntry)) error {
+	rootAbs, err := filepath.Abs(root)
+	if err != nil {
+		return err
+	}
+	info, err := os.Stat(rootAbs)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return errors.New("path is not a directory: " + rootAbs)
+	}
+
+	var ignore *gitignore
+	if opts.RespectGitignore {
+		ignore = newGitignore(rootAbs)
+	}
+
+	err = walkFS(os.DirFS(rootAbs), filepath.ToSlash(rootAbs), opts, ignore, func(p string, d fs.DirEntry) {
+		visit(filepath.Join(rootAbs, filepath.FromSlash(p)), d)
+	})
+	var pathErr *fs.PathError
+	if errors.As(err, &pathErr) {
+		// report OS paths, not paths within the DirFS
+		pathErr.Path = filepath.Join(rootAbs, filepath.FromSlash(pathErr.Path))
+	}
+	return err
+}
+
+// pathDepth returns how many directories below the walk root the slash
+// path p lies: a directory counts itself, a file counts the directory it is
+// in. So "a" is at depth 1 as a directory but "a/requirements.txt" is also at
+// depth 1, and a file directly in the root is at depth 0. p is cleaned first
+// so that stray "./" elements or trailing slashes do not change the count.
+func pathDepth(p string, dir bool) int {
+	p = path.Clean(p)
+	if p == "." {
+		return 0
+	}
+	n := strings.Count(p, "/")
+	if dir {
+		n++
+	}
+	return n
+}
+
+// walkFS walks fsys applying the depth limit and exclusions in opts, and
+// calls visit with the slash-separated path of every file that survives
+// them. base is the name of the fsys root in the path space of ignore's
+// rules; ignore may be nil. Each directory's .gitignore is loaded from fsys.
+func walkFS(fsys fs.FS, base string, opts DiscoverOptions, ignore *gitignore, visit func(p string, d fs.DirEntry)) error {
+	if opts.Workers > 1 && ignore == nil && !opts.FollowSymlinks {
+		return walkFSParallel(fsys, opts, visit)
+	}
+	// real paths of the directories walked so far; symlinks can only be
+	// resolved, and so followed, when base is an OS path
+	var visited map[string]bool
+	if opts.FollowSymlinks && base != "" {
+		visited = map[string]bool{}
+	}
+	// symlinked directories are walked after the tree they were found in, so a
+	// directory reachable both directly and through a link keeps its real path
+	var pending []string
+	var walk func(start string) error
+	walk = func(start string) error {
+		return fs.WalkDir(fsys, start, func(p string, d fs.DirEntry, walkErr error) error {
+			if walkErr != nil {
+				return walkErr
+			}
+			if p != "." && pruned(p, d, opts) {
+				if d.IsDir() {
+					return fs.SkipDir
+				}
+				return nil
+			}
+			if ignore != nil {
+				if p != "." && ignore.ignored(joinBase(base, p), d.IsDir()) {
+					if d.IsDir() {
+						return fs.SkipDir
+					}
+					return nil
+				}
+				if d.IsDir() {
+					ignore.loadFS(fsys, p, joinBase(base, p))
+				}
+			}
+			if visited != nil {
+				if d.IsDir() {
+					real, err := filepath.EvalSymlinks(filepath.FromSlash(joinBase(base, p)))
+					if err != nil {
+						return err
+					}
+					if visited[real] {
+						// a symlink cycle, or a tree already reached another way
+						return fs.SkipDir
+					}
+					visited[real] = true
+				} else if d.Type()&fs.ModeSymlink != 0 {
+					if info, err := fs.Stat(fsys, p); err == nil && info.IsDir() {
+						pending = append(pending, p)
+						return nil
+					}
+				}
+			}
+			if !d.IsDir() {
+				visit(p, d)
+			}
+			return nil
+		})
+	}
+	if err := walk("."); err != nil {
+		return err
+	}
+	for len(pending) > 0 {
+		p := pending[0]
+		pending = pending[1:]
+		if err := walk(p); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// pruned reports whether the walk leaves out p, a path below the walk root:
+// anything beyond the depth limit, and directories that are skipped by name
+// or excluded.
+func pruned(p string, d fs.DirEntry, opts DiscoverOptions) bool {
+	if opts.MaxDepth >= 0 && pathDepth(p, d.IsDir()) > opts.MaxDepth {
+		return true
+	}
+	if !d.IsDir() {
+		return false
+	}
+	for _, name := range opts.SkipNames {
+		if strings.EqualFold(d.Name(), name) {
+			return true
+		}
+	}
+	return excluded(p, opts.Excludes)
+}
+
+// walkFSParallel is walkFS without .gitignore rules or symlink following,
+// reading up to opts.Workers directories at once. Files are collected
+// first and visited in the order a sequential walk would visit them; the
+// first error stops the walk.
+func walkFSParallel(fsys fs.FS, opts DiscoverOptions, visit func(p string, d fs.DirEntry)) error {
+	type file struct {
+		p string
+		d fs.DirEntry
+	}
+	var (
+		mu       sync.Mutex
+		files    []file
+		firstErr error
+		wg       sync.WaitGroup
+	)
+	sem := make(chan struct{}, opts.Workers)
+	var read func(dir string)
+	read = func(dir string) {
+		defer wg.Done()
+		sem <- struct{}{}
+		mu.Lock()
+		failed := firstErr != nil
+		mu.Unlock()
+		var entries []fs.DirEntry
+		var err error
+		if !failed {
+			entries, err = fs.ReadDir(fsys, dir)
+		}
+		<-sem
+		if err != nil {
+			mu.Lock()
+			if firstErr == nil {
+				firstErr = err
+			}
+			mu.Unlock()
+			return
+		}
+		var found []file
+		for _, d := range entries {
+			p := path.Join(dir, d.Name())
+			if pruned(p, d, opts) {
+				continue
+			}
+			if d.IsDir() {
+				wg.Add(1)
+				go read(p)
+				continue
+			}
+			found = append(found, file{p, d})
+		}
+		mu.Lock()
+		files = append(files, found...)
+		mu.Unlock()
+	}
+	wg.Add(1)
+	go read(".")
+	wg.Wait()
+	if firstErr != nil {
+		return firstErr
+	}
+
+	// fs.WalkDir order: lexical within a directory, depth first
+	slices.SortFunc(files, func(a, b file) int {
+		return slices.Compare(strings.Split(a.p, "/"), strings.Split(b.p, "/"))
+	})
+	for _, f := range files {
+		visit(f.p, f.d)
+	}
+	return nil
+}
+
+// joinBase joins the fs path p onto base, treating "." as base itself.
+func joinBase(base, p string) string {
+	switch {
+	case p == ".":
+		return base
+	case base == "":
+		return p
+	}
+	return base + "/" + p
+}
+
+// excluded reports whether the relative path rel matches any of patterns.
+// Each pattern is tried against the full path and against every trailing
+// run of path segments, so ".git" matches at any depth and "a/b" matches
+// "x/a/b". A leading "**/" is accepted and means the same thing.
+func excluded(rel string, patterns []string) bool {
+	segs := strings.Split(filepath.ToSlash(rel), "/")
+	for _, p := range patterns {
+		p = filepath.ToSlash(p)
+		for strings.HasPrefix(p, "**/") {
+			p = strings.TrimPrefix(p, "**/")
+		}
+		for i := range segs {
+			if ok, _ := path.Match(p, strings.Join(segs[i:], "/")); ok {
+				return true
+			}
+		}
+	}
+	return false
+}
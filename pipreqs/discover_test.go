@@ -0,0 +1,383 @@
+package pipreqs
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+	"testing/fstest"
+)
+
+// makeTree creates the given files (slash-separated, relative to root) with
+// empty contents.
+func makeTree(t *testing.T, root string, files ...string) {
+	t.Helper()
+	for _, f := range files {
+		p := filepath.Join(root, filepath.FromSlash(f))
+		if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// relDirs maps absolute discovery results back to slash-separated paths
+// relative to root.
+func relDirs(t *testing.T, root string, dirs []string) []string {
+	t.Helper()
+	out := make([]string, 0, len(dirs))
+	for _, d := range dirs {
+		rel, err := filepath.Rel(root, d)
+		if err != nil {
+			t.Fatal(err)
+		}
+		out = append(out, filepath.ToSlash(rel))
+	}
+	sort.Strings(out)
+	return out
+}
+
+// targetDirs returns the directory of each discovered target.
+func targetDirs(targets []Target) []string {
+	dirs := make([]string, len(targets))
+	for i, t := range targets {
+		dirs[i] = t.Dir
+	}
+	return dirs
+}
+
+func TestFindRequirementsDirsExclude(t *testing.T) {
+	root := t.TempDir()
+	makeTree(t, root,
+		"requirements.txt",
+		"svc/requirements.txt",
+		"svc/.venv/lib/requirements.txt",
+		".venv/requirements.txt",
+		".git/requirements.txt",
+		"node_modules/pkg/requirements.txt",
+		"tools/build/requirements.txt",
+	)
+	tests := []struct {
+		name     string
+		excludes []string
+		want     []string
+	}{
+		{"none", nil, []string{".", ".git", ".venv", "node_modules/pkg", "svc", "svc/.venv/lib", "tools/build"}},
+		{"segment", []string{".git"}, []string{".", ".venv", "node_modules/pkg", "svc", "svc/.venv/lib", "tools/build"}},
+		{"double star", []string{"**/.venv"}, []string{".", ".git", "node_modules/pkg", "svc", "tools/build"}},
+		{"glob", []string{"node_*"}, []string{".", ".git", ".venv", "svc", "svc/.venv/lib", "tools/build"}},
+		{"full path", []string{"tools/build"}, []string{".", ".git", ".venv", "node_modules/pkg", "svc", "svc/.venv/lib"}},
+		{"several", []string{".git", ".venv", "node_modules"}, []string{".", "svc", "tools/build"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dirs, err := FindRequirementsDirs(root, DiscoverOptions{MaxDepth: -1, Excludes: tt.excludes})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := relDirs(t, root, targetDirs(dirs)); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("dirs = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFindRequirementsDirsDefaultExcludes(t *testing.T) {
+	root := t.TempDir()
+	makeTree(t, root,
+		"app/requirements.txt",
+		"app/.venv/requirements.txt",
+		"VENV/requirements.txt",
+		"__pycache__/requirements.txt",
+		"Node_Modules/requirements.txt",
+		".git/requirements.txt",
+		"venvs/requirements.txt",
+	)
+	dirs, err := FindRequirementsDirs(root, DiscoverOptions{MaxDepth: -1, SkipNames: DefaultExcludeDirs})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := relDirs(t, root, targetDirs(dirs)), []string{"app", "venvs"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("dirs = %q, want %q", got, want)
+	}
+}
+
+func TestMultipleFilenames(t *testing.T) {
+	root := t.TempDir()
+	makeTree(t, root, "a/requirements.txt", "a/requirements-dev.txt", "b/requirements-dev.txt", "c/other.txt")
+
+	targets, err := FindRequirementsDirs(root, DiscoverOptions{MaxDepth: -1, Filenames: []string{"requirements.txt", "requirements-dev.txt"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	SortTargets(targets)
+	var got []string
+	for _, tg := range targets {
+		rel, _ := filepath.Rel(root, tg.Path())
+		got = append(got, filepath.ToSlash(rel))
+	}
+	want := []string{"a/requirements-dev.txt", "a/requirements.txt", "b/requirements-dev.txt"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("targets = %q, want %q", got, want)
+	}
+}
+
+// denyFS fails to open the named directory, standing in for one the walk
+// must never enter.
+type denyFS struct {
+	fs.FS
+	deny string
+}
+
+func (d denyFS) Open(name string) (fs.File, error) {
+	if name == d.deny {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrPermission}
+	}
+	return d.FS.Open(name)
+}
+
+func TestMaxDepth(t *testing.T) {
+	fsys := fstest.MapFS{
+		"requirements.txt":       {},
+		"a/requirements.txt":     {},
+		"a/b/requirements.txt":   {},
+		"a/b/c/requirements.txt": {},
+	}
+	tests := []struct {
+		depth int
+		deny  string // first directory below the limit; opening it fails
+		want  []string
+	}{
+		{0, "a", []string{"."}},
+		{1, "a/b", []string{".", "a"}},
+		{2, "a/b/c", []string{".", "a", "a/b"}},
+		{-1, "", []string{".", "a", "a/b", "a/b/c"}},
+	}
+	for _, tt := range tests {
+		for _, workers := range []int{0, 4} {
+			t.Run(fmt.Sprintf("depth %d workers %d", tt.depth, workers), func(t *testing.T) {
+				targets, err := FindRequirementsDirsFS(denyFS{fsys, tt.deny}, DiscoverOptions{MaxDepth: tt.depth, Workers: workers})
+				if err != nil {
+					t.Fatalf("walk entered a directory beyond max depth: %v", err)
+				}
+				SortTargets(targets)
+				if got := targetDirs(targets); !reflect.DeepEqual(got, tt.want) {
+					t.Errorf("dirs = %q, want %q", got, tt.want)
+				}
+			})
+		}
+	}
+}
+
+func TestPathDepth(t *testing.T) {
+	tests := []struct {
+		p    string
+		dir  bool
+		want int
+	}{
+		{".", true, 0},
+		{"requirements.txt", false, 0},
+		{"a", true, 1},
+		{"a/", true, 1},
+		{"./a/requirements.txt", false, 1},
+		{"a//b/requirements.txt", false, 2},
+		{"a/b/c", true, 3},
+	}
+	for _, tt := range tests {
+		if got := pathDepth(tt.p, tt.dir); got != tt.want {
+			t.Errorf("pathDepth(%q, %v) = %d, want %d", tt.p, tt.dir, got, tt.want)
+		}
+	}
+}
+
+func TestMaxDepthBoundary(t *testing.T) {
+	root := t.TempDir()
+	// one requirements file at each depth 0 through 3
+	makeTree(t, root, "requirements.txt", "d1/requirements.txt", "d1/d2/requirements.txt", "d1/d2/d3/requirements.txt")
+	levels := []string{".", "d1", "d1/d2", "d1/d2/d3"}
+	sep := string(filepath.Separator)
+
+	for _, spelling := range []string{root, root + sep, root + sep + "." + sep} {
+		for depth := -1; depth <= 3; depth++ {
+			want := levels
+			if depth >= 0 {
+				want = levels[:depth+1]
+			}
+			targets, err := FindRequirementsDirs(spelling, DiscoverOptions{MaxDepth: depth})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := relDirs(t, root, targetDirs(targets)); !reflect.DeepEqual(got, want) {
+				t.Errorf("root %q, MaxDepth %d: found %q, want %q", spelling, depth, got, want)
+			}
+		}
+	}
+}
+
+func TestUnlimitedDepth(t *testing.T) {
+	root := t.TempDir()
+	deep := "a/b/c/d/e/f/g/h"
+	makeTree(t, root, deep+"/requirements.txt")
+
+	for _, depth := range []int{2, 7} {
+		targets, err := FindRequirementsDirs(root, DiscoverOptions{MaxDepth: depth})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(targets) != 0 {
+			t.Errorf("MaxDepth %d found %q", depth, targetDirs(targets))
+		}
+	}
+	targets, err := FindRequirementsDirs(root, DiscoverOptions{MaxDepth: UnlimitedDepth})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := relDirs(t, root, targetDirs(targets)); !reflect.DeepEqual(got, []string{deep}) {
+		t.Errorf("unlimited found %q, want %q", got, deep)
+	}
+}
+
+func TestFollowSymlinks(t *testing.T) {
+	root := t.TempDir()
+	shared := t.TempDir()
+	makeTree(t, root, "app/requirements.txt")
+	makeTree(t, shared, "lib/requirements.txt")
+	links := map[string]string{
+		"app/shared": shared,                     // a subtree outside root
+		"app/loop":   root,                       // a cycle back to root
+		"again":      filepath.Join(root, "app"), // a second way into app
+	}
+	for link, target := range links {
+		if err := os.Symlink(target, filepath.Join(root, filepath.FromSlash(link))); err != nil {
+			t.Skip("symlinks not supported:", err)
+		}
+	}
+
+	for _, follow := range []bool{false, true} {
+		targets, err := FindRequirementsDirs(root, DiscoverOptions{MaxDepth: UnlimitedDepth, FollowSymlinks: follow})
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := []string{"app"}
+		if follow {
+			// app/loop and "again" lead to directories already walked, and
+			// app keeps its real path even though "again" sorts first
+			want = []string{"app", "app/shared/lib"}
+		}
+		if got := relDirs(t, root, targetDirs(targets)); !reflect.DeepEqual(got, want) {
+			t.Errorf("FollowSymlinks %v: found %q, want %q", follow, got, want)
+		}
+	}
+}
+
+func TestFindRequirementsDirsFS(t *testing.T) {
+	fsys := fstest.MapFS{
+		"requirements.txt":              {},
+		"a/requirements.txt":            {},
+		"a/REQUIREMENTS.TXT":            {},
+		"a/b/requirements.txt":          {},
+		"a/b/c/requirements.txt":        {},
+		"a/b/c/d/requirements.txt":      {},
+		"node_modules/requirements.txt": {},
+		"gen/requirements.txt":          {},
+		".gitignore":                    {Data: []byte("gen/\n")},
+	}
+	tests := []struct {
+		name string
+		opts DiscoverOptions
+		want []string
+	}{
+		{"depth 0", DiscoverOptions{MaxDepth: 0}, []string{"."}},
+		{"depth 2", DiscoverOptions{MaxDepth: 2, SkipNames: DefaultExcludeDirs}, []string{".", "a", "a/b", "gen"}},
+		{"unlimited", DiscoverOptions{MaxDepth: -1, SkipNames: DefaultExcludeDirs}, []string{".", "a", "a/b", "a/b/c", "a/b/c/d", "gen"}},
+		{"gitignore", DiscoverOptions{MaxDepth: -1, SkipNames: DefaultExcludeDirs, RespectGitignore: true}, []string{".", "a", "a/b", "a/b/c", "a/b/c/d"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			targets, err := FindRequirementsDirsFS(fsys, tt.opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			SortTargets(targets)
+			// "a" holds two case variants of the name but yields one target
+			if got := targetDirs(targets); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("dirs = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParallelWalk(t *testing.T) {
+	fsys := fstest.MapFS{
+		"requirements.txt":                {},
+		"a/requirements.txt":              {},
+		"a/x/requirements.txt":            {},
+		"a-b/requirements.txt":            {},
+		"a-b/dev.txt":                     {},
+		"b/build/requirements.txt":        {},
+		"b/c/d/e/requirements.txt":        {},
+		"node_modules/x/requirements.txt": {},
+	}
+	for _, opts := range []DiscoverOptions{
+		{MaxDepth: -1},
+		{MaxDepth: 2, SkipNames: DefaultExcludeDirs},
+		{MaxDepth: -1, Excludes: []string{"build"}, Filenames: []string{"requirements.txt", "dev.txt"}},
+	} {
+		want, err := FindRequirementsDirsFS(fsys, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		opts.Workers = 4
+		got, err := FindRequirementsDirsFS(fsys, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// same targets in the same order as the sequential walk
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%+v: parallel walk = %v, want %v", opts, got, want)
+		}
+	}
+
+	_, err := FindRequirementsDirsFS(denyFS{fsys, "b/c"}, DiscoverOptions{MaxDepth: -1, Workers: 4})
+	if !errors.Is(err, fs.ErrPermission) {
+		t.Errorf("err = %v, want the unreadable directory's error", err)
+	}
+}
+
+// BenchmarkFindRequirementsDirs compares the sequential and parallel walks
+// over a tree six levels deep with four subdirectories in each directory.
+func BenchmarkFindRequirementsDirs(b *testing.B) {
+	root := b.TempDir()
+	var grow func(dir string, depth int)
+	grow = func(dir string, depth int) {
+		if err := os.WriteFile(filepath.Join(dir, "requirements.txt"), nil, 0o644); err != nil {
+			b.Fatal(err)
+		}
+		if depth == 0 {
+			return
+		}
+		for i := range 4 {
+			sub := filepath.Join(dir, fmt.Sprint("d", i))
+			if err := os.Mkdir(sub, 0o755); err != nil {
+				b.Fatal(err)
+			}
+			grow(sub, depth-1)
+		}
+	}
+	grow(root, 6)
+	for _, workers := range []int{0, 8} {
+		b.Run(fmt.Sprint("workers ", workers), func(b *testing.B) {
+			for b.Loop() {
+				if _, err := FindRequirementsDirs(root, DiscoverOptions{MaxDepth: -1, Workers: workers}); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,232 @@
+package pipreqs
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// ErrNotGitRepo is returned for directories outside any git work tree.
+var ErrNotGitRepo = errors.New("not in a git repository")
+
+// git runs git with args in dir and returns its standard output. A failure
+// carries git's standard error, untranslated so that callers can match it.
+func git(dir string, args ...string) ([]byte, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "LC_ALL=C")
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("git %s: %w: %s", strings.Join(args, " "), err, msg)
+		}
+		return nil, fmt.Errorf("git %s: %w", strings.Join(args, " "), err)
+	}
+	return out, nil
+}
+
+// gitTopLevel returns the root of the git work tree containing dir. The
+// error wraps ErrNotGitRepo when dir is outside any work tree.
+func gitTopLevel(dir string) (string, error) {
+	out, err := git(dir, "rev-parse", "--show-toplevel")
+	if err != nil {
+		if strings.Contains(err.Error(), "not a git repository") {
+			return "", fmt.Errorf("%s: %w", dir, ErrNotGitRepo)
+		}
+		return "", err
+	}
+	return filepath.FromSlash(strings.TrimSpace(string(out))), nil
+}
+
+// gitChangedFiles returns the absolute paths of the files in the work tree
+// at top that differ from ref, as listed by git diff --name-only.
+func gitChangedFiles(top, ref string) ([]string, error) {
+	out, err := git(top, "diff", "--name-only", "-z", ref, "--")
+	if err != nil {
+		return nil, err
+	}
+	var files []string
+	for _, name := range strings.Split(string(out), "\x00") {
+		if name != "" {
+			files = append(files, filepath.Join(top, filepath.FromSlash(name)))
+		}
+	}
+	return files, nil
+}
+
+// gitDirty reports whether git status shows uncommitted changes to the
+// file at path, including it being untracked. A file outside any git
+// repository is never dirty; failing to run git at all is an error.
+func gitDirty(path string) (bool, error) {
+	dir := filepath.Dir(path)
+	if _, err := gitTopLevel(dir); errors.Is(err, ErrNotGitRepo) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	out, err := git(dir, "status", "--porcelain", "--", filepath.Base(path))
+	if err != nil {
+		return false, err
+	}
+	return len(bytes.TrimSpace(out)) > 0, nil
+}
+
+// filterChanged returns the targets whose directory holds a Python source
+// (or notebook, when notebooks is set) that changed since ref in its git
+// repository. Targets outside any git repository are all kept, with a
+// warning; an unknown ref is an error.
+func filterChanged(targets []Target, ref string, notebooks bool, logger *slog.Logger) ([]Target, error) {
+	changed := map[string][]string{} // work tree root -> changed sources
+	var kept []Target
+	outside := 0
+	for _, t := range targets {
+		top, err := gitTopLevel(t.Dir)
+		if err != nil {
+			outside++
+			kept = append(kept, t)
+			continue
+		}
+		sources, ok := changed[top]
+		if !ok {
+			files, err := gitChangedFiles(top, ref)
+			if err != nil {
+				return nil, err
+			}
+			for _, f := range files {
+				if isWatchedSource(f, notebooks) {
+					sources = append(sources, f)
+				}
+			}
+			changed[top] = sources
+		}
+		// git reports paths below the resolved work tree root
+		dir, err := filepath.Abs(t.Dir)
+		if err == nil {
+			if real, err := filepath.EvalSymlinks(dir); err == nil {
+				dir = real
+			}
+		}
+		if slices.ContainsFunc(sources, func(f string) bool { return within(dir, f) }) {
+			kept = append(kept, t)
+		}
+	}
+	if outside > 0 {
+		logger.Warn("not in a git repository; processing regardless of the changed-since ref", "ref", ref, "targets", outside)
+	}
+	if n := len(targets) - len(kept); n > 0 {
+		logger.Info("no Python changes since ref; not processing", "ref", ref, "targets", n)
+	}
+	return kept, nil
+}
+
+// CommitChanges stages the requirements files that s updated and commits
+// them, and nothing else, with message in each git repository holding one.
+// It returns the work tree roots it committed in; no updated file means no
+// commit. Every file must be inside a git repository, which is checked
+// before anything is staged.
+func CommitChanges(s Summary, message string) ([]string, error) {
+	files := map[string][]string{} // work tree root -> updated files
+	var tops []string
+	for _, r := range s.Results {
+		if !r.Changed || r.Err != nil {
+			continue
+		}
+		// git names files below the resolved work tree root, and of a
+		// symlinked requirements file only the target changed
+		path, err := filepath.Abs(r.Path())
+		if err != nil {
+			return nil, err
+		}
+		if real, err := filepath.EvalSymlinks(path); err == nil {
+			path = real
+		}
+		top, err := gitTopLevel(filepath.Dir(path))
+		if err != nil {
+			return nil, fmt.Errorf("cannot commit %s: %w", path, ErrNotGitRepo)
+		}
+		if _, ok := files[top]; !ok {
+			tops = append(tops, top)
+		}
+		files[top] = append(files[top], path)
+	}
+	for _, top := range tops {
+		paths := files[top]
+		if _, err := git(top, append([]string{"add", "--"}, paths...)...); err != nil {
+			return nil, err
+		}
+		// naming the paths commits only them, leaving anything else that
+		// was staged for the user
+		if _, err := git(top, append([]string{"commit", "-q", "-m", message, "--"}, paths...)...); err != nil {
+			return nil, err
+		}
+	}
+	return tops, nil
+}
+
+// stashMessage labels the stash entries GitStash creates.
+const stashMessage = "quick_pipreqs: changes set aside while regenerating requirements"
+
+// Stash is a set of uncommitted changes put aside by GitStash.
+type Stash struct {
+	Dir    string // root of the work tree the changes belong to
+	commit string // the stash entry's commit, to find it again after other stashes
+}
+
+// GitStash stashes the uncommitted changes to tracked files, staged or not,
+// in the git work tree containing dir, so that the work tree matches HEAD.
+// Untracked files stay where they are. It returns nil if there was nothing
+// to stash.
+func GitStash(dir string) (*Stash, error) {
+	top, err := gitTopLevel(dir)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", dir, ErrNotGitRepo)
+	}
+	before := stashHead(top)
+	if _, err := git(top, "stash", "push", "-q", "-m", stashMessage); err != nil {
+		return nil, err
+	}
+	after := stashHead(top)
+	if after == "" || after == before {
+		// git stash makes no entry for a clean work tree
+		return nil, nil
+	}
+	return &Stash{Dir: top, commit: after}, nil
+}
+
+// stashHead returns the commit of the newest stash entry in the work tree
+// at top, or "" if there is none.
+func stashHead(top string) string {
+	out, err := git(top, "stash", "list", "-n", "1", "--format=%H")
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(out))
+}
+
+// Pop restores the stashed changes, including which of them were staged,
+// and drops the stash entry. If they cannot be applied, for example because
+// they conflict with files changed since, the entry is left in place and
+// the error names it.
+func (s *Stash) Pop() error {
+	out, err := git(s.Dir, "stash", "list", "--format=%H")
+	if err != nil {
+		return err
+	}
+	i := slices.Index(strings.Fields(string(out)), s.commit)
+	if i < 0 {
+		return fmt.Errorf("stash %.12s is no longer in the stash list of %s", s.commit, s.Dir)
+	}
+	ref := fmt.Sprintf("stash@{%d}", i)
+	if _, err := git(s.Dir, "stash", "pop", "-q", "--index", ref); err != nil {
+		return fmt.Errorf("restoring the changes stashed in %s failed; they are kept as %s (%.12s), apply them with git stash pop once resolved: %w", s.Dir, ref, s.commit, err)
+	}
+	return nil
+}
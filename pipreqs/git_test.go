@@ -0,0 +1,207 @@
+package pipreqs
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// gitRepo turns dir into a git repository with everything in it committed.
+func gitRepo(t *testing.T, dir string) {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	for _, v := range []string{"GIT_AUTHOR_NAME", "GIT_COMMITTER_NAME"} {
+		t.Setenv(v, "test")
+	}
+	for _, v := range []string{"GIT_AUTHOR_EMAIL", "GIT_COMMITTER_EMAIL"} {
+		t.Setenv(v, "test@example.com")
+	}
+	for _, args := range [][]string{
+		{"init", "-q"},
+		{"config", "commit.gpgsign", "false"},
+		{"add", "-A"},
+		{"commit", "-q", "-m", "initial"},
+	} {
+		if _, err := git(dir, args...); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestFindTargetsChangedSince(t *testing.T) {
+	root := t.TempDir()
+	makeTree(t, root, "a/requirements.txt", "a/app.py", "b/requirements.txt", "b/app.py", "c/requirements.txt", "c/app.py", "c/venv/lib.py")
+	gitRepo(t, root)
+	writeFile(t, filepath.Join(root, "b", "app.py"), "import requests\n")
+	writeFile(t, filepath.Join(root, "c", "venv", "lib.py"), "import ignored\n")
+	writeFile(t, filepath.Join(root, "a", "requirements.txt"), "flask\n")
+
+	targets, err := FindTargets(Config{Root: root, Discover: DiscoverOptions{MaxDepth: 1}, ChangedSince: "HEAD"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Target{{Dir: filepath.Join(root, "b"), Filename: "requirements.txt"}}
+	if !reflect.DeepEqual(targets, want) {
+		t.Errorf("targets = %v, want only b", targets)
+	}
+
+	if _, err := FindTargets(Config{Root: root, Discover: DiscoverOptions{MaxDepth: 1}, ChangedSince: "no-such-ref"}); err == nil || !strings.Contains(err.Error(), "no-such-ref") {
+		t.Errorf("unknown ref: err = %v", err)
+	}
+}
+
+func TestFindTargetsChangedSinceOutsideGit(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	root := t.TempDir()
+	makeTree(t, root, "a/requirements.txt", "a/app.py", "b/requirements.txt", "b/app.py")
+	targets, err := FindTargets(Config{Root: root, Discover: DiscoverOptions{MaxDepth: 1}, ChangedSince: "HEAD"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(targets) != 2 {
+		t.Errorf("targets = %v, want every target outside a git repository", targets)
+	}
+}
+
+func TestCommitChanges(t *testing.T) {
+	root := t.TempDir()
+	makeTree(t, root, "a/requirements.txt", "a/app.py", "b/requirements.txt", "b/app.py", "notes.txt")
+	gitRepo(t, root)
+	writeFile(t, filepath.Join(root, "a", "requirements.txt"), "requests==2.31.0\n")
+	writeFile(t, filepath.Join(root, "a", "requirements.txt.bak"), "")
+	writeFile(t, filepath.Join(root, "notes.txt"), "staged by the user\n")
+	if _, err := git(root, "add", "notes.txt"); err != nil {
+		t.Fatal(err)
+	}
+
+	s := Summarize([]Result{
+		{Dir: filepath.Join(root, "a"), Filename: "requirements.txt", Changed: true},
+		{Dir: filepath.Join(root, "b"), Filename: "requirements.txt"},
+	})
+	repos, err := CommitChanges(s, "Regenerate requirements")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(repos) != 1 {
+		t.Fatalf("committed in %v, want one repository", repos)
+	}
+	out, err := git(root, "show", "--name-only", "--format=%s", "HEAD")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := strings.Fields(string(out)), []string{"Regenerate", "requirements", "a/requirements.txt"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("HEAD = %q, want %q", got, want)
+	}
+	if out, _ := git(root, "diff", "--cached", "--name-only"); strings.TrimSpace(string(out)) != "notes.txt" {
+		t.Errorf("staged after commit: %q, want the user's notes.txt left staged", out)
+	}
+
+	if repos, err := CommitChanges(Summarize([]Result{{Dir: filepath.Join(root, "b"), Filename: "requirements.txt"}}), "nothing"); err != nil || len(repos) != 0 {
+		t.Errorf("without changes: committed in %v, err %v", repos, err)
+	}
+
+	outside := t.TempDir()
+	_, err = CommitChanges(Summarize([]Result{{Dir: outside, Filename: "requirements.txt", Changed: true}}), "outside")
+	if err == nil || !strings.Contains(err.Error(), "not in a git repository") {
+		t.Errorf("outside a repository: err = %v", err)
+	}
+}
+
+func TestUpdateRequirementsSkipDirty(t *testing.T) {
+	root := t.TempDir()
+	makeTree(t, root, "clean/requirements.txt", "clean/app.py", "dirty/requirements.txt", "dirty/app.py")
+	gitRepo(t, root)
+	writeFile(t, filepath.Join(root, "dirty", "requirements.txt"), "flask==3.0.0  # pinned by hand\n")
+	fake := &fakeRunner{content: "requests==2.31.0\n"}
+
+	for _, tt := range []struct {
+		dir   string
+		force bool
+		skip  bool
+	}{
+		{"clean", false, false},
+		{"dirty", false, true},
+		{"dirty", true, false},
+	} {
+		res := UpdateRequirements(context.Background(), filepath.Join(root, tt.dir), Options{Runner: fake, SkipDirty: true, Force: tt.force})
+		if res.Skipped() != tt.skip || (!tt.skip && res.Err != nil) {
+			t.Errorf("%s (force=%v): err = %v, want skipped %v", tt.dir, tt.force, res.Err, tt.skip)
+		}
+	}
+
+	// outside a repository there is nothing to check
+	if res := UpdateRequirements(context.Background(), t.TempDir(), Options{Runner: fake, SkipDirty: true}); res.Err != nil {
+		t.Errorf("outside git: %v", res.Err)
+	}
+
+	// without git the file cannot be checked, which must not pass as clean
+	t.Setenv("PATH", t.TempDir())
+	res := UpdateRequirements(context.Background(), filepath.Join(root, "dirty"), Options{Runner: fake, SkipDirty: true})
+	if !errors.Is(res.Err, exec.ErrNotFound) {
+		t.Errorf("without git: err = %v, want exec.ErrNotFound", res.Err)
+	}
+}
+
+func TestGitStash(t *testing.T) {
+	root := t.TempDir()
+	makeTree(t, root, "requirements.txt", "app.py")
+	gitRepo(t, root)
+	reqPath := filepath.Join(root, "requirements.txt")
+
+	if st, err := GitStash(root); err != nil || st != nil {
+		t.Fatalf("clean tree: stash %v, err %v; want nothing stashed", st, err)
+	}
+
+	writeFile(t, reqPath, "flask  # by hand\n")
+	st, err := GitStash(root)
+	if err != nil || st == nil {
+		t.Fatalf("GitStash = %v, %v", st, err)
+	}
+	if got, _ := os.ReadFile(reqPath); len(got) != 0 {
+		t.Errorf("requirements.txt after stashing = %q, want the committed (empty) file", got)
+	}
+	if err := st.Pop(); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := os.ReadFile(reqPath); string(got) != "flask  # by hand\n" {
+		t.Errorf("requirements.txt after popping = %q, want the hand edit back", got)
+	}
+
+	// a conflicting change keeps the stash
+	if st, err = GitStash(root); err != nil || st == nil {
+		t.Fatalf("GitStash = %v, %v", st, err)
+	}
+	writeFile(t, reqPath, "requests==2.31.0\n")
+	if err := st.Pop(); err == nil || !strings.Contains(err.Error(), "stash@{0}") {
+		t.Errorf("conflicting pop: err = %v, want one naming the kept stash", err)
+	}
+	if out, _ := git(root, "stash", "list"); !strings.Contains(string(out), stashMessage) {
+		t.Errorf("stash list = %q, want the stash kept", out)
+	}
+
+	if _, err := GitStash(t.TempDir()); !errors.Is(err, ErrNotGitRepo) {
+		t.Errorf("outside git: err = %v, want ErrNotGitRepo", err)
+	}
+}
+
+func TestGitTopLevelLocale(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	// git translates its messages; outside a repository must still be
+	// recognised under any locale
+	t.Setenv("LC_ALL", "de_DE.UTF-8")
+	t.Setenv("LANGUAGE", "de")
+	if _, err := gitTopLevel(t.TempDir()); !errors.Is(err, ErrNotGitRepo) {
+		t.Errorf("err = %v, want ErrNotGitRepo", err)
+	}
+}
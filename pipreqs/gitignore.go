@@ -0,0 +1,148 @@
+package pipreqs
+
+import (
+	"bufio"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// gitignoreRule is a single pattern line from a .gitignore file.
+type gitignoreRule struct {
+	base     string   // slash-separated directory holding the .gitignore; "" for an fs.FS root
+	segs     []string // pattern split on "/"
+	negate   bool     // "!pattern" re-includes a previously ignored path
+	dirOnly  bool     // "pattern/" only matches directories
+	anchored bool     // pattern contains a "/" and is relative to base
+}
+
+// gitignore accumulates rules from every .gitignore seen during a walk.
+// Rules are kept in load order, so a deeper file (loaded later) overrides
+// a shallower one, and within a file the last matching line wins.
+type gitignore struct {
+	rules []gitignoreRule
+}
+
+// newGitignore returns a matcher preloaded with the .gitignore files between
+// the enclosing git repository root (if any) and dir, exclusive of dir itself.
+// The walk loads dir's own .gitignore when it visits it.
+func newGitignore(dir string) *gitignore {
+	g := &gitignore{}
+	if _, err := os.Stat(filepath.Join(dir, ".git")); err == nil {
+		return g
+	}
+	var parents []string
+	for p := filepath.Dir(dir); ; p = filepath.Dir(p) {
+		parents = append(parents, p)
+		if _, err := os.Stat(filepath.Join(p, ".git")); err == nil {
+			break
+		}
+		if filepath.Dir(p) == p {
+			// not inside a repository; only nested files apply
+			parents = nil
+			break
+		}
+	}
+	for i := len(parents) - 1; i >= 0; i-- {
+		g.load(parents[i])
+	}
+	return g
+}
+
+// load reads dir/.gitignore, if present, and appends its rules.
+func (g *gitignore) load(dir string) {
+	g.loadFS(os.DirFS(dir), ".", filepath.ToSlash(dir))
+}
+
+// loadFS reads dir/.gitignore from fsys, if present, and appends its rules
+// for paths below base.
+func (g *gitignore) loadFS(fsys fs.FS, dir, base string) {
+	f, err := fsys.Open(path.Join(dir, ".gitignore"))
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		line := strings.TrimRight(sc.Text(), " \t\r")
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		r := gitignoreRule{base: base}
+		if strings.HasPrefix(line, "!") {
+			r.negate = true
+			line = line[1:]
+		} else if strings.HasPrefix(line, `\`) {
+			// "\#" and "\!" escape a literal leading character
+			line = line[1:]
+		}
+		if strings.HasSuffix(line, "/") {
+			r.dirOnly = true
+			line = strings.TrimRight(line, "/")
+		}
+		if strings.Contains(line, "/") {
+			r.anchored = true
+			line = strings.TrimPrefix(line, "/")
+		}
+		if line == "" {
+			continue
+		}
+		r.segs = strings.Split(line, "/")
+		g.rules = append(g.rules, r)
+	}
+}
+
+// ignored reports whether the path p, in the same space as the rules'
+// bases, is ignored by the loaded rules.
+func (g *gitignore) ignored(p string, isDir bool) bool {
+	p = filepath.ToSlash(p)
+	ignored := false
+	for _, r := range g.rules {
+		if r.dirOnly && !isDir {
+			continue
+		}
+		rel, ok := p, true
+		if r.base != "" {
+			rel, ok = strings.CutPrefix(p, r.base+"/")
+		}
+		if !ok || rel == "" {
+			continue
+		}
+		segs := strings.Split(rel, "/")
+		var match bool
+		if r.anchored {
+			match = matchSegments(r.segs, segs)
+		} else {
+			match, _ = path.Match(r.segs[0], segs[len(segs)-1])
+		}
+		if match {
+			ignored = !r.negate
+		}
+	}
+	return ignored
+}
+
+// matchSegments matches path segments against pattern segments, where a
+// "**" pattern segment matches zero or more path segments.
+func matchSegments(pat, segs []string) bool {
+	if len(pat) == 0 {
+		return len(segs) == 0
+	}
+	if pat[0] == "**" {
+		for i := 0; i <= len(segs); i++ {
+			if matchSegments(pat[1:], segs[i:]) {
+				return true
+			}
+		}
+		return false
+	}
+	if len(segs) == 0 {
+		return false
+	}
+	if ok, _ := path.Match(pat[0], segs[0]); !ok {
+		return false
+	}
+	return matchSegments(pat[1:], segs[1:])
+}
@@ -0,0 +1,58 @@
+package pipreqs
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestFindRequirementsDirsRespectGitignore(t *testing.T) {
+	root := t.TempDir()
+	makeTree(t, root,
+		".git/HEAD",
+		"requirements.txt",
+		"build/requirements.txt",
+		"dist/keep/requirements.txt",
+		"svc/requirements.txt",
+		"svc/generated/requirements.txt",
+		"svc/generated/pinned/requirements.txt",
+		"svc/out/requirements.txt",
+		"lib/requirements.txt",
+		"lib/tmp-a/requirements.txt",
+		"lib/tmp-b/requirements.txt",
+		"docs/a/b/requirements.txt",
+	)
+	writeFile(t, filepath.Join(root, ".gitignore"), "# build output\nbuild/\ntmp-*\n!tmp-b\n/docs/**/b\n")
+	writeFile(t, filepath.Join(root, "svc", ".gitignore"), "generated\nout/*\n")
+
+	dirs, err := FindRequirementsDirs(root, DiscoverOptions{MaxDepth: -1, RespectGitignore: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{".", "dist/keep", "lib", "lib/tmp-b", "svc"}
+	if got := relDirs(t, root, targetDirs(dirs)); !reflect.DeepEqual(got, want) {
+		t.Errorf("dirs = %q, want %q", got, want)
+	}
+}
+
+func TestGitignoreParentRules(t *testing.T) {
+	repo := t.TempDir()
+	makeTree(t, repo, ".git/HEAD", "sub/requirements.txt", "sub/vendor/requirements.txt")
+	writeFile(t, filepath.Join(repo, ".gitignore"), "vendor/\n")
+
+	dirs, err := FindRequirementsDirs(filepath.Join(repo, "sub"), DiscoverOptions{MaxDepth: -1, RespectGitignore: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := relDirs(t, repo, targetDirs(dirs)), []string{"sub"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("dirs = %q, want %q", got, want)
+	}
+}
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
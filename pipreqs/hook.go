@@ -0,0 +1,129 @@
+package pipreqs
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// ErrHookFailed wraps the error of a post-hook that did not succeed,
+// together with its captured output.
+var ErrHookFailed = errors.New("hook failed")
+
+// Environment variables set for hooks.
+const (
+	hookEnvDir     = "QUICK_PIPREQS_DIR"     // absolute directory being processed
+	hookEnvFile    = "QUICK_PIPREQS_FILE"    // absolute path of its requirements file
+	hookEnvChanged = "QUICK_PIPREQS_CHANGED" // post-hook only: "true" if the file was updated
+)
+
+// shellCommand returns the command that runs script with the system shell.
+func shellCommand(ctx context.Context, script string) *exec.Cmd {
+	if runtime.GOOS == "windows" {
+		return exec.CommandContext(ctx, "cmd", "/C", script)
+	}
+	return exec.CommandContext(ctx, "sh", "-c", script)
+}
+
+// runHook runs the shell command script in dir for res's requirements file,
+// with the hook variables and extra added to the environment. Its combined
+// output is copied line by line to o.HookOutput while it runs, if set, and
+// the end of it is kept for the error.
+func (o Options) runHook(ctx context.Context, name, script, dir string, res *Result, extra ...string) error {
+	absDir, err := filepath.Abs(dir)
+	if err != nil {
+		return err
+	}
+	cmd := shellCommand(ctx, script)
+	cmd.Dir = dir
+	cmd.WaitDelay = cmdWaitDelay
+	cmd.Env = append(os.Environ(), hookEnvDir+"="+absDir, hookEnvFile+"="+filepath.Join(absDir, res.Filename))
+	cmd.Env = append(cmd.Env, extra...)
+	out := &tailBuffer{max: DefaultMaxOutput}
+	var w io.Writer = out
+	if o.HookOutput != nil {
+		lw := &lineWriter{w: o.HookOutput, prefix: res.Path() + ": " + name + ": "}
+		defer lw.flush()
+		w = io.MultiWriter(out, lw)
+	}
+	cmd.Stdout, cmd.Stderr = w, w
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() != nil {
+			return fmt.Errorf("%s cancelled: %w", name, ctx.Err())
+		}
+		msg := fmt.Sprintf("%s %q: %v", name, script, err)
+		if output := strings.TrimRight(string(out.Bytes()), "\n"); output != "" {
+			msg += "\n" + output
+		}
+		return errors.New(msg)
+	}
+	return nil
+}
+
+// withHooks runs update between o.PreHook and o.PostHook. A failing
+// pre-hook skips the directory; a failing post-hook fails it with
+// ErrHookFailed. Dry runs run neither, since hooks may change files.
+func (o Options) withHooks(ctx context.Context, dir string, res *Result, update func() error) error {
+	if o.DryRun {
+		return update()
+	}
+	if o.PreHook != "" {
+		if err := o.runHook(ctx, "pre-hook", o.PreHook, dir, res); err != nil {
+			if ctx.Err() != nil {
+				return err
+			}
+			return fmt.Errorf("%w: %w", ErrSkip, err)
+		}
+	}
+	if err := update(); err != nil || o.PostHook == "" {
+		return err
+	}
+	if err := o.runHook(ctx, "post-hook", o.PostHook, dir, res, hookEnvChanged+"="+strconv.FormatBool(res.Changed)); err != nil {
+		if ctx.Err() != nil {
+			return err
+		}
+		return fmt.Errorf("%w: %w", ErrHookFailed, err)
+	}
+	return nil
+}
+
+// lineWriter writes each complete line written to it to w in a single
+// Write, after prefix, so that lines from concurrent hooks do not mix.
+type lineWriter struct {
+	w      io.Writer
+	prefix string
+	buf    []byte
+}
+
+func (l *lineWriter) Write(p []byte) (int, error) {
+	l.buf = append(l.buf, p...)
+	for {
+		i := bytes.IndexByte(l.buf, '\n')
+		if i < 0 {
+			return len(p), nil
+		}
+		l.writeLine(l.buf[:i+1])
+		l.buf = l.buf[i+1:]
+	}
+}
+
+// flush writes a last line that lacks its newline.
+func (l *lineWriter) flush() {
+	if len(l.buf) > 0 {
+		l.writeLine(append(l.buf, '\n'))
+		l.buf = nil
+	}
+}
+
+// writeLine writes line to w; a failing HookOutput must not fail the hook.
+func (l *lineWriter) writeLine(line []byte) {
+	_, _ = l.w.Write(append([]byte(l.prefix), line...))
+}
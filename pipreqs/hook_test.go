@@ -0,0 +1,87 @@
+package pipreqs
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestUpdateRequirementsHooks(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("hooks in these tests are POSIX shell")
+	}
+	fake := &fakeRunner{content: "requests==2.31.0\n"}
+	run := func(t *testing.T, opts Options) (string, Result) {
+		t.Helper()
+		dir := t.TempDir()
+		writeFile(t, filepath.Join(dir, "requirements.txt"), "requests==2.31.0\n")
+		opts.Runner = fake
+		return dir, UpdateRequirements(context.Background(), dir, opts)
+	}
+
+	t.Run("order and environment", func(t *testing.T) {
+		fake.calls = nil
+		var out bytes.Buffer
+		dir, res := run(t, Options{
+			PreHook:    `echo "pre $QUICK_PIPREQS_FILE" >> hooks.log`,
+			PostHook:   `echo "post $QUICK_PIPREQS_CHANGED" >> hooks.log; echo done`,
+			HookOutput: &out,
+		})
+		if res.Err != nil {
+			t.Fatal(res.Err)
+		}
+		got, _ := os.ReadFile(filepath.Join(dir, "hooks.log"))
+		want := "pre " + filepath.Join(dir, "requirements.txt") + "\npost false\n"
+		if string(got) != want || len(fake.calls) != 1 {
+			t.Errorf("hooks.log = %q, %d pipreqs calls; want %q around one call", got, len(fake.calls), want)
+		}
+		if want := filepath.Join(dir, "requirements.txt") + ": post-hook: done\n"; out.String() != want {
+			t.Errorf("hook output = %q, want %q", out.String(), want)
+		}
+	})
+
+	t.Run("failing pre-hook skips", func(t *testing.T) {
+		fake.calls = nil
+		_, res := run(t, Options{PreHook: "echo not today; exit 3", PostHook: "echo never"})
+		if !res.Skipped() || !strings.Contains(res.Err.Error(), "not today") || len(fake.calls) != 0 {
+			t.Errorf("err = %v, %d pipreqs calls; want a skip carrying the output, pipreqs not run", res.Err, len(fake.calls))
+		}
+	})
+
+	t.Run("failing post-hook fails", func(t *testing.T) {
+		_, res := run(t, Options{PostHook: "echo broken >&2; exit 1"})
+		if !errors.Is(res.Err, ErrHookFailed) || res.Category() != CategoryHook || !strings.Contains(res.Err.Error(), "broken") {
+			t.Errorf("err = %v (category %q), want a hook failure carrying the output", res.Err, res.Category())
+		}
+	})
+
+	t.Run("post-hook edits are not manual edits", func(t *testing.T) {
+		dir := t.TempDir()
+		reqPath := filepath.Join(dir, "requirements.txt")
+		writeFile(t, reqPath, "flask==3.0.0\n")
+		res := UpdateRequirements(context.Background(), dir, Options{Runner: fake, PostHook: "echo '# sorted' >> requirements.txt"})
+		if res.Err != nil || res.BackupPath == "" {
+			t.Fatalf("err = %v, backup %q; want an update with a backup", res.Err, res.BackupPath)
+		}
+		if edited, err := EditedSinceBackup(reqPath, res.BackupPath); err != nil || edited {
+			t.Errorf("edited since backup = %v, %v; want the post-hook's edit to count as the run's output", edited, err)
+		}
+	})
+
+	t.Run("dry run skips hooks", func(t *testing.T) {
+		dir, res := run(t, Options{DryRun: true, PreHook: "touch pre", PostHook: "touch post"})
+		if res.Err != nil {
+			t.Fatal(res.Err)
+		}
+		for _, name := range []string{"pre", "post"} {
+			if _, err := os.Stat(filepath.Join(dir, name)); err == nil {
+				t.Errorf("dry run ran the %s-hook", name)
+			}
+		}
+	})
+}
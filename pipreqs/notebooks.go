@@ -0,0 +1,156 @@
+package pipreqs
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// notebookShimSuffix is appended to a notebook's name to form the .py file
+// holding its imports in the temporary mirror pipreqs scans.
+const notebookShimSuffix = ".quick_pipreqs.py"
+
+// notebook is the subset of the nbformat 4 schema needed to read code cells.
+type notebook struct {
+	Cells []struct {
+		CellType string          `json:"cell_type"`
+		Source   json.RawMessage `json:"source"`
+	} `json:"cells"`
+}
+
+// notebookImports returns the import statements found in the code cells of
+// the notebook at path, dedented so each is valid at module level. Only
+// imports are kept: magics, shell escapes, and other cell code may not parse
+// as plain Python, and pipreqs aborts on files it cannot parse.
+func notebookImports(path string) ([]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var nb notebook
+	if err := json.Unmarshal(data, &nb); err != nil {
+		return nil, fmt.Errorf("%s: malformed notebook: %w", path, err)
+	}
+	var imports []string
+	for _, cell := range nb.Cells {
+		if cell.CellType != "code" {
+			continue
+		}
+		// source is either a string or a list of line strings
+		var src string
+		var lines []string
+		if err := json.Unmarshal(cell.Source, &lines); err == nil {
+			src = strings.Join(lines, "")
+		} else if err := json.Unmarshal(cell.Source, &src); err != nil {
+			return nil, fmt.Errorf("%s: malformed cell source: %w", path, err)
+		}
+		imports = append(imports, importStatements(src)...)
+	}
+	return imports, nil
+}
+
+// importStatements extracts "import x" and "from x import y" statements from
+// Python source, joining parenthesized and backslash-continued lines.
+func importStatements(src string) []string {
+	var out []string
+	lines := strings.Split(src, "\n")
+	for i := 0; i < len(lines); i++ {
+		line := strings.TrimSpace(lines[i])
+		if !strings.HasPrefix(line, "import ") && !(strings.HasPrefix(line, "from ") && strings.Contains(line, " import")) {
+			continue
+		}
+		stmt := line
+		for i+1 < len(lines) && (strings.HasSuffix(stmt, `\`) || strings.Count(stmt, "(") > strings.Count(stmt, ")")) {
+			i++
+			stmt = strings.TrimSuffix(stmt, `\`) + " " + strings.TrimSpace(lines[i])
+		}
+		out = append(out, stmt)
+	}
+	return out
+}
+
+// notebookScanDir returns the directory pipreqs should scan in place of dir
+// to see the imports of its notebooks: a temporary mirror of the .py files
+// pipreqs would scan under dir (symlinked, or copied where links are not
+// allowed) with, next to every notebook, a shim holding its imports. The
+// source tree is never written to, so dry runs stay read-only and nested
+// targets running at once do not share shims. Malformed notebooks are
+// skipped; without any notebook imports, dir itself is returned. The
+// returned cleanup must always be called, even on error.
+func notebookScanDir(dir string) (scanDir string, cleanup func(), err error) {
+	type shim struct {
+		rel     string
+		imports []string
+	}
+	var sources []string
+	var shims []shim
+	err = filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if path != dir && slices.Contains(pythonIgnoreDirs, d.Name()) {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		switch ext := filepath.Ext(d.Name()); {
+		case strings.EqualFold(ext, ".py"):
+			sources = append(sources, rel)
+		case strings.EqualFold(ext, ".ipynb"):
+			imports, err := notebookImports(path)
+			if err != nil {
+				// unreadable notebooks contribute nothing rather than failing the run
+				return nil
+			}
+			if len(imports) > 0 {
+				shims = append(shims, shim{rel + notebookShimSuffix, imports})
+			}
+		}
+		return nil
+	})
+	if err != nil || len(shims) == 0 {
+		return dir, func() {}, err
+	}
+
+	mirror, err := os.MkdirTemp("", "quick_pipreqs-notebooks-")
+	if err != nil {
+		return dir, func() {}, err
+	}
+	cleanup = func() { _ = os.RemoveAll(mirror) }
+	for _, rel := range sources {
+		dst := filepath.Join(mirror, rel)
+		if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+			return dir, cleanup, err
+		}
+		abs, err := filepath.Abs(filepath.Join(dir, rel))
+		if err != nil {
+			return dir, cleanup, err
+		}
+		if os.Symlink(abs, dst) != nil {
+			if err := copyFile(abs, dst); err != nil {
+				return dir, cleanup, err
+			}
+		}
+	}
+	for _, s := range shims {
+		dst := filepath.Join(mirror, s.rel)
+		if err := os.MkdirAll(filepath.Dir(dst), 0o755); err != nil {
+			return dir, cleanup, err
+		}
+		// never write through a mirrored source of the same name
+		_ = os.Remove(dst)
+		if err := os.WriteFile(dst, []byte(strings.Join(s.imports, "\n")+"\n"), 0o644); err != nil {
+			return dir, cleanup, err
+		}
+	}
+	return mirror, cleanup, nil
+}
@@ -0,0 +1,96 @@
+package pipreqs
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestNotebookImports(t *testing.T) {
+	dir := t.TempDir()
+	nb := filepath.Join(dir, "analysis.ipynb")
+	writeFile(t, nb, `{
+ "cells": [
+  {"cell_type": "markdown", "source": ["import not_code\n"]},
+  {"cell_type": "code", "source": ["%matplotlib inline\n", "import numpy as np\n", "from pandas import (\n", "    DataFrame,\n", "    Series)\n"]},
+  {"cell_type": "code", "source": "!pip install x\nif True:\n    import requests\nprint(np.pi)"}
+ ],
+ "nbformat": 4
+}`)
+	got, err := notebookImports(nb)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"import numpy as np", "from pandas import ( DataFrame, Series)", "import requests"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("imports = %q, want %q", got, want)
+	}
+}
+
+func TestNotebookScanDir(t *testing.T) {
+	dir := t.TempDir()
+	makeTree(t, dir, "pkg/app.py", "venv/lib.py")
+	writeFile(t, filepath.Join(dir, "pkg", "good.ipynb"), `{"cells": [{"cell_type": "code", "source": ["import flask\n"]}]}`)
+	writeFile(t, filepath.Join(dir, "broken.ipynb"), `{"cells": [`)
+
+	scanDir, cleanup, err := notebookScanDir(dir)
+	if err != nil {
+		t.Fatalf("malformed notebook should be skipped, got %v", err)
+	}
+	if scanDir == dir {
+		t.Fatal("scan dir is the source tree")
+	}
+	if got, err := os.ReadFile(filepath.Join(scanDir, "pkg", "good.ipynb"+notebookShimSuffix)); err != nil || string(got) != "import flask\n" {
+		t.Errorf("shim = %q, %v", got, err)
+	}
+	if _, err := os.Stat(filepath.Join(scanDir, "pkg", "app.py")); err != nil {
+		t.Errorf("mirror lacks pkg/app.py: %v", err)
+	}
+	for _, name := range []string{"venv/lib.py", "broken.ipynb" + notebookShimSuffix} {
+		if _, err := os.Stat(filepath.Join(scanDir, filepath.FromSlash(name))); !os.IsNotExist(err) {
+			t.Errorf("mirror has %s", name)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dir, "pkg", "good.ipynb"+notebookShimSuffix)); !os.IsNotExist(err) {
+		t.Error("wrote a shim into the source tree")
+	}
+	cleanup()
+	if _, err := os.Stat(scanDir); !os.IsNotExist(err) {
+		t.Error("cleanup left the mirror behind")
+	}
+
+	// without notebook imports pipreqs scans the tree itself
+	plain := t.TempDir()
+	makeTree(t, plain, "app.py")
+	if scanDir, cleanup, err := notebookScanDir(plain); err != nil || scanDir != plain {
+		t.Errorf("scan dir = %q, %v; want %q", scanDir, err, plain)
+	} else {
+		cleanup()
+	}
+}
+
+func TestUpdateRequirementsNotebooksReadOnly(t *testing.T) {
+	for _, dryRun := range []bool{false, true} {
+		dir := t.TempDir()
+		makeTree(t, dir, "requirements.txt", "app.py")
+		writeFile(t, filepath.Join(dir, "nb.ipynb"), `{"cells": [{"cell_type": "code", "source": ["import flask\n"]}]}`)
+		sawShim := false
+		runner := RunnerFunc(func(ctx context.Context, bin string, args []string, workDir string) ([]byte, error) {
+			_, err := os.Stat(filepath.Join(args[0], "nb.ipynb"+notebookShimSuffix))
+			sawShim = err == nil
+			return nil, os.WriteFile(savePath(args, workDir), []byte("flask==3.0.0\n"), 0o644)
+		})
+		res := UpdateRequirements(context.Background(), dir, Options{Runner: runner, ScanNotebooks: true, DryRun: dryRun, NoBackup: true})
+		if res.Err != nil {
+			t.Fatal(res.Err)
+		}
+		if !sawShim {
+			t.Errorf("dry run %t: pipreqs did not see the notebook's imports", dryRun)
+		}
+		if _, err := os.Stat(filepath.Join(dir, "nb.ipynb"+notebookShimSuffix)); !os.IsNotExist(err) {
+			t.Errorf("dry run %t: a shim was written into the source tree", dryRun)
+		}
+	}
+}
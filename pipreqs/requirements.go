@@ -0,0 +1,446 @@
+package pipreqs
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// requirement is a parsed requirement specifier line such as
+// "Flask[async]>=2.0,<3 ; python_version >= '3.8'".
+type requirement struct {
+	Name      string   // distribution name as written
+	Extras    []string // optional extras inside [...]
+	Specifier string   // version specifiers, e.g. "==1.2" or ">=1,<2"; may be empty
+	Marker    string   // environment marker after ";"; may be empty
+}
+
+var (
+	reqNameRe = regexp.MustCompile(`^([A-Za-z0-9](?:[A-Za-z0-9._-]*[A-Za-z0-9])?)\s*`)
+	extraRe   = regexp.MustCompile(`^[A-Za-z0-9](?:[A-Za-z0-9._-]*[A-Za-z0-9])?$`)
+	reqSpecRe = regexp.MustCompile(`^(~=|===|==|!=|<=|>=|<|>)\s*([A-Za-z0-9.*+!_-]+)$`)
+)
+
+// parseRequirement parses a single requirements line. Blank lines, comments,
+// pip option lines (e.g. "-r other.txt"), and VCS/URL installs yield
+// ok == false.
+func parseRequirement(line string) (req requirement, ok bool, err error) {
+	line = stripComment(line)
+	if line == "" || strings.HasPrefix(line, "-") || isURLRequirement(line) {
+		return requirement{}, false, nil
+	}
+	m := reqNameRe.FindStringSubmatch(line)
+	if m == nil {
+		return requirement{}, false, fmt.Errorf("invalid requirement name in %q", line)
+	}
+	req.Name = m[1]
+	rest := line[len(m[0]):]
+
+	if strings.HasPrefix(rest, "[") {
+		end := strings.IndexByte(rest, ']')
+		if end < 0 {
+			return requirement{}, false, fmt.Errorf("unterminated extras in %q", line)
+		}
+		for _, e := range strings.Split(rest[1:end], ",") {
+			e = strings.TrimSpace(e)
+			if !extraRe.MatchString(e) {
+				return requirement{}, false, fmt.Errorf("invalid extra %q in %q", e, line)
+			}
+			req.Extras = append(req.Extras, e)
+		}
+		rest = strings.TrimSpace(rest[end+1:])
+	}
+
+	if i := strings.IndexByte(rest, ';'); i >= 0 {
+		req.Marker = strings.TrimSpace(rest[i+1:])
+		if req.Marker == "" {
+			return requirement{}, false, fmt.Errorf("empty environment marker in %q", line)
+		}
+		rest = strings.TrimSpace(rest[:i])
+	}
+
+	if rest != "" {
+		var specs []string
+		for _, spec := range strings.Split(rest, ",") {
+			sm := reqSpecRe.FindStringSubmatch(strings.TrimSpace(spec))
+			if sm == nil {
+				return requirement{}, false, fmt.Errorf("invalid version specifier %q in %q", strings.TrimSpace(spec), line)
+			}
+			specs = append(specs, sm[1]+sm[2])
+		}
+		req.Specifier = strings.Join(specs, ",")
+	}
+	return req, true, nil
+}
+
+// isURLRequirement reports whether line installs from version control or a
+// direct URL, e.g. "git+https://..." or "pkg @ https://...".
+func isURLRequirement(line string) bool {
+	for _, vcs := range []string{"git+", "hg+", "svn+", "bzr+"} {
+		if strings.HasPrefix(line, vcs) {
+			return true
+		}
+	}
+	return strings.Contains(line, "://")
+}
+
+// urlRequirementName returns the package a VCS/URL line installs, taken from
+// "name @ url" or a "#egg=name" fragment, or "" if it cannot be determined.
+func urlRequirementName(line string) string {
+	if i := strings.Index(line, " @ "); i > 0 {
+		return strings.TrimSpace(line[:i])
+	}
+	if i := strings.Index(line, "#egg="); i >= 0 {
+		name := line[i+len("#egg="):]
+		if j := strings.IndexAny(name, "&# \t"); j >= 0 {
+			name = name[:j]
+		}
+		return name
+	}
+	return ""
+}
+
+// urlRequirements returns the VCS/URL install lines in content, trimmed.
+func urlRequirements(content []byte) []string {
+	var out []string
+	for _, line := range strings.Split(string(content), "\n") {
+		line = strings.TrimSpace(line)
+		if line != "" && !strings.HasPrefix(line, "#") && isURLRequirement(line) {
+			out = append(out, line)
+		}
+	}
+	return out
+}
+
+// withURLRequirements appends urls to the generated content. A line already
+// present is not repeated, and a generated entry for a package that one of
+// urls installs is dropped in favour of the URL.
+func withURLRequirements(content []byte, urls []string) []byte {
+	if len(urls) == 0 {
+		return content
+	}
+	have := make(map[string]bool)
+	replaced := make(map[string]bool)
+	for _, u := range urls {
+		if name := urlRequirementName(u); name != "" {
+			replaced[canonicalName(name)] = true
+		}
+	}
+	var b bytes.Buffer
+	for _, line := range strings.SplitAfter(string(content), "\n") {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" {
+			b.WriteString(line)
+			continue
+		}
+		if req, ok, err := parseRequirement(trimmed); err == nil && ok && replaced[canonicalName(req.Name)] {
+			continue
+		}
+		have[trimmed] = true
+		b.WriteString(line)
+		if !strings.HasSuffix(line, "\n") {
+			b.WriteByte('\n')
+		}
+	}
+	for _, u := range urls {
+		if !have[u] {
+			have[u] = true
+			b.WriteString(u + "\n")
+		}
+	}
+	return b.Bytes()
+}
+
+// stripComment removes a trailing "# comment" (pip requires whitespace
+// before an inline comment) and surrounding whitespace.
+func stripComment(line string) string {
+	line = strings.TrimSpace(line)
+	if strings.HasPrefix(line, "#") {
+		return ""
+	}
+	if i := strings.Index(line, " #"); i >= 0 {
+		line = line[:i]
+	}
+	if i := strings.Index(line, "\t#"); i >= 0 {
+		line = line[:i]
+	}
+	return strings.TrimSpace(line)
+}
+
+// parseRequirements parses every requirement line in content. Errors carry
+// the 1-based line number of the first unparseable line.
+func parseRequirements(content []byte) ([]requirement, error) {
+	var reqs []requirement
+	for i, line := range strings.Split(string(content), "\n") {
+		req, ok, err := parseRequirement(line)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", i+1, err)
+		}
+		if ok {
+			reqs = append(reqs, req)
+		}
+	}
+	return reqs, nil
+}
+
+// String formats the requirement as a requirements.txt line.
+func (r requirement) String() string {
+	var b strings.Builder
+	b.WriteString(r.Name)
+	if len(r.Extras) > 0 {
+		b.WriteString("[" + strings.Join(r.Extras, ",") + "]")
+	}
+	b.WriteString(r.Specifier)
+	if r.Marker != "" {
+		b.WriteString("; " + r.Marker)
+	}
+	return b.String()
+}
+
+var canonicalRunRe = regexp.MustCompile(`[-_.]+`)
+
+// canonicalName normalizes a distribution name per PEP 503: lowercased,
+// with runs of "-", "_" and "." collapsed to a single "-".
+func canonicalName(name string) string {
+	return strings.ToLower(canonicalRunRe.ReplaceAllString(name, "-"))
+}
+
+// mergeRequirements keeps the package set of generated but, for packages
+// also present in old, the old entry (version specifier, extras, marker),
+// so manual pins survive regeneration. The result is sorted by canonical
+// name.
+func mergeRequirements(old, generated []requirement) []requirement {
+	pinned := make(map[string]requirement, len(old))
+	for _, r := range old {
+		pinned[canonicalName(r.Name)] = r
+	}
+	out := make([]requirement, 0, len(generated))
+	seen := make(map[string]bool, len(generated))
+	for _, r := range generated {
+		key := canonicalName(r.Name)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		if p, ok := pinned[key]; ok {
+			r = p
+		}
+		out = append(out, r)
+	}
+	sortRequirements(out)
+	return out
+}
+
+// mergeExisting merges the requirements pipreqs generated with the
+// existing file old, see mergeRequirements. Lines of old that are not
+// plain requirements are returned as written in kept rather than failing
+// the merge: pip options such as "-r other.txt" or "-e .", and package
+// lines with options such as "--hash=...", which stand in for the
+// generated entry, or are dropped with it. A line continued with a
+// trailing backslash counts as one.
+func mergeExisting(old []byte, generated []requirement) (kept []string, reqs []requirement) {
+	var pins []requirement
+	written := make(map[string]string)
+	unfold := strings.NewReplacer("\\\r\n", " ", "\\\n", " ")
+	for _, raw := range continuedLines(old) {
+		line := strings.TrimSpace(raw)
+		req, ok, err := parseRequirement(unfold.Replace(line))
+		switch {
+		case err == nil && ok:
+			pins = append(pins, req)
+		case err == nil:
+			if strings.HasPrefix(stripComment(line), "-") {
+				kept = append(kept, line)
+			}
+		default:
+			if m := reqNameRe.FindStringSubmatch(line); m != nil {
+				written[canonicalName(m[1])] = line
+			} else {
+				kept = append(kept, line)
+			}
+		}
+	}
+	for _, r := range mergeRequirements(pins, generated) {
+		if line, ok := written[canonicalName(r.Name)]; ok {
+			kept = append(kept, line)
+		} else {
+			reqs = append(reqs, r)
+		}
+	}
+	return kept, reqs
+}
+
+// continuedLines splits content into lines, joining a line that ends in a
+// backslash with the next one as pip does; joined lines keep their breaks.
+func continuedLines(content []byte) []string {
+	lines := strings.Split(string(content), "\n")
+	var out []string
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		for strings.HasSuffix(strings.TrimSuffix(line, "\r"), "\\") && i+1 < len(lines) {
+			i++
+			line += "\n" + lines[i]
+		}
+		out = append(out, line)
+	}
+	return out
+}
+
+// normalizeRequirements canonicalizes every name (see canonicalName) and
+// sorts the result, leaving specifiers, extras, and markers as they are.
+func normalizeRequirements(reqs []requirement) []requirement {
+	out := make([]requirement, len(reqs))
+	for i, r := range reqs {
+		r.Name = canonicalName(r.Name)
+		out[i] = r
+	}
+	sortRequirements(out)
+	return out
+}
+
+// sortRequirements orders reqs by canonical name.
+func sortRequirements(reqs []requirement) {
+	sort.SliceStable(reqs, func(i, j int) bool {
+		return canonicalName(reqs[i].Name) < canonicalName(reqs[j].Name)
+	})
+}
+
+// formatRequirements renders reqs one per line.
+func formatRequirements(reqs []requirement) []byte {
+	var b bytes.Buffer
+	for _, r := range reqs {
+		b.WriteString(r.String())
+		b.WriteByte('\n')
+	}
+	return b.Bytes()
+}
+
+// ErrInvalidRequirements is returned by --validate for output that does not
+// parse as a requirements file.
+var ErrInvalidRequirements = errors.New("invalid generated requirements")
+
+// ErrTooManyRemoved is returned when regeneration would drop more than
+// --max-removed-pct of the existing packages.
+var ErrTooManyRemoved = errors.New("too many packages removed")
+
+// postProcessing reports whether postProcess has anything to do.
+func (o Options) postProcessing() bool {
+	return o.PreserveHeader || o.Merge || o.PreserveVCS || o.Normalize || o.Validate ||
+		(o.MaxRemovedPct > 0 && !o.Force)
+}
+
+// packageNames maps the canonical name of each package in content to the
+// name as written. Unparseable lines are ignored.
+func packageNames(content []byte) map[string]string {
+	names := make(map[string]string)
+	for _, line := range strings.Split(string(content), "\n") {
+		if req, ok, err := parseRequirement(line); err == nil && ok {
+			names[canonicalName(req.Name)] = req.Name
+		}
+	}
+	return names
+}
+
+// packageDiff returns the packages in new but not old, and in old but not
+// new, compared by canonical name and each sorted by it.
+func packageDiff(old, new []byte) (added, removed []string) {
+	before, after := packageNames(old), packageNames(new)
+	for _, key := range slices.Sorted(maps.Keys(after)) {
+		if _, ok := before[key]; !ok {
+			added = append(added, after[key])
+		}
+	}
+	for _, key := range slices.Sorted(maps.Keys(before)) {
+		if _, ok := after[key]; !ok {
+			removed = append(removed, before[key])
+		}
+	}
+	return added, removed
+}
+
+// postProcess rewrites the freshly generated requirements file at path
+// according to opts. old is the content of the file being replaced, or nil
+// if there was none.
+func postProcess(path string, old []byte, opts Options) error {
+	if !opts.postProcessing() {
+		return nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	out := data
+	var kept []string // lines of old that --merge keeps as written
+	if opts.Merge && old != nil {
+		newReqs, err := parseRequirements(out)
+		if err != nil {
+			return fmt.Errorf("merge: parsing generated file: %w", err)
+		}
+		var reqs []requirement
+		kept, reqs = mergeExisting(old, newReqs)
+		out = formatRequirements(reqs)
+	}
+	if opts.Normalize {
+		reqs, err := parseRequirements(out)
+		if err != nil {
+			return fmt.Errorf("normalize: %w", err)
+		}
+		out = formatRequirements(normalizeRequirements(reqs))
+	}
+	if opts.PreserveVCS {
+		out = withURLRequirements(out, urlRequirements(old))
+	}
+	if opts.Validate {
+		if _, err := parseRequirements(out); err != nil {
+			return fmt.Errorf("%w: %w", ErrInvalidRequirements, err)
+		}
+	}
+	if len(kept) > 0 {
+		out = append([]byte(strings.Join(kept, "\n")+"\n"), out...)
+	}
+	if opts.PreserveHeader {
+		out = withHeader(out, headerComment(old))
+	}
+	if opts.MaxRemovedPct > 0 && !opts.Force {
+		if total := len(packageNames(old)); total > 0 {
+			_, removed := packageDiff(old, out)
+			// compare without dividing, so 1 of 3 is over a 33% limit
+			if len(removed)*100 > opts.MaxRemovedPct*total {
+				return fmt.Errorf("%w: %d of %d packages (%.1f%%) would be removed, limit is %d%%; use --force to accept",
+					ErrTooManyRemoved, len(removed), total, float64(len(removed)*100)/float64(total), opts.MaxRemovedPct)
+			}
+		}
+	}
+	if bytes.Equal(out, data) {
+		return nil
+	}
+	return os.WriteFile(path, out, 0o644)
+}
+
+// headerComment returns the leading run of lines beginning with "#" in
+// content, including their line endings.
+func headerComment(content []byte) []byte {
+	n := 0
+	for n < len(content) && content[n] == '#' {
+		i := bytes.IndexByte(content[n:], '\n')
+		if i < 0 {
+			return append(content[:len(content):len(content)], '\n')
+		}
+		n += i + 1
+	}
+	return content[:n]
+}
+
+// withHeader prepends header to content unless content already starts with it.
+func withHeader(content, header []byte) []byte {
+	if len(header) == 0 || bytes.HasPrefix(content, header) {
+		return content
+	}
+	return append(append([]byte(nil), header...), content...)
+}
@@ -0,0 +1,227 @@
+package pipreqs
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestHeaderComment(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"", ""},
+		{"requests\n", ""},
+		{"# License: MIT\n# Owner: infra\nrequests\n# trailing\n", "# License: MIT\n# Owner: infra\n"},
+		{"# only", "# only\n"},
+		{"\n# after blank\n", ""},
+	}
+	for _, tt := range tests {
+		if got := string(headerComment([]byte(tt.in))); got != tt.want {
+			t.Errorf("headerComment(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestUpdateRequirementsPreserveHeader(t *testing.T) {
+	tests := []struct {
+		name        string
+		existing    string
+		wantChanged bool
+	}{
+		{"same packages", "# Copyright\nrequests==2.31.0\n", false},
+		{"new packages", "# Copyright\nflask==3.0.0\n", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := &fakeRunner{content: "requests==2.31.0\n"}
+			dir := t.TempDir()
+			reqPath := filepath.Join(dir, "requirements.txt")
+			writeFile(t, reqPath, tt.existing)
+
+			res := UpdateRequirements(context.Background(), dir, Options{Runner: fake, PreserveHeader: true})
+			if res.Err != nil {
+				t.Fatal(res.Err)
+			}
+			if res.Changed != tt.wantChanged {
+				t.Errorf("changed = %v, want %v", res.Changed, tt.wantChanged)
+			}
+			if got, _ := os.ReadFile(reqPath); string(got) != "# Copyright\nrequests==2.31.0\n" {
+				t.Errorf("requirements.txt = %q", got)
+			}
+		})
+	}
+}
+
+func TestParseRequirement(t *testing.T) {
+	tests := []struct {
+		line    string
+		want    requirement
+		ok      bool
+		wantErr bool
+	}{
+		{line: "requests==2.31.0", want: requirement{Name: "requests", Specifier: "==2.31.0"}, ok: true},
+		{line: "Flask[async, dotenv] >= 2.0, <3  # web", want: requirement{Name: "Flask", Extras: []string{"async", "dotenv"}, Specifier: ">=2.0,<3"}, ok: true},
+		{line: "zope.interface", want: requirement{Name: "zope.interface"}, ok: true},
+		{line: "tomli; python_version < '3.11'", want: requirement{Name: "tomli", Marker: "python_version < '3.11'"}, ok: true},
+		{line: "numpy~=1.26.0", want: requirement{Name: "numpy", Specifier: "~=1.26.0"}, ok: true},
+		{line: "   "},
+		{line: "# comment"},
+		{line: "-r base.txt"},
+		{line: "git+https://github.com/org/repo.git#egg=repo"},
+		{line: "pkg @ https://example.com/pkg.whl"},
+		{line: "requests=2.31.0", wantErr: true},
+		{line: "requests[security", wantErr: true},
+		{line: "requests==", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, ok, err := parseRequirement(tt.line)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseRequirement(%q) err = %v, wantErr %v", tt.line, err, tt.wantErr)
+			continue
+		}
+		if ok != tt.ok || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseRequirement(%q) = %+v, %v; want %+v, %v", tt.line, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestMergeRequirements(t *testing.T) {
+	old, err := parseRequirements([]byte("Django>=4.2,<5\nrequests[socks]==2.28.0\nurllib3==1.26.0\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	generated, err := parseRequirements([]byte("requests==2.31.0\nnumpy==1.26.4\ndjango==5.0.1\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := string(formatRequirements(mergeRequirements(old, generated)))
+	want := "Django>=4.2,<5\nnumpy==1.26.4\nrequests[socks]==2.28.0\n"
+	if got != want {
+		t.Errorf("merged =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestUpdateRequirementsMergeKeepsOptionLines(t *testing.T) {
+	fake := &fakeRunner{content: "requests==2.31.0\nflask==3.1.0\nnumpy==1.26.4\n"}
+	dir := t.TempDir()
+	reqPath := filepath.Join(dir, "requirements.txt")
+	writeFile(t, reqPath, "-r base.txt\n-e .\n"+
+		"flask==3.0.0 \\\n    --hash=sha256:abc\n"+
+		"requests==2.28.0\n"+
+		"urllib3==1.26.0 --hash=sha256:def\n")
+
+	if err := UpdateRequirements(context.Background(), dir, Options{Runner: fake, Merge: true}).Err; err != nil {
+		t.Fatal(err)
+	}
+	got, _ := os.ReadFile(reqPath)
+	want := "-r base.txt\n-e .\nflask==3.0.0 \\\n    --hash=sha256:abc\nnumpy==1.26.4\nrequests==2.28.0\n"
+	if string(got) != want {
+		t.Errorf("requirements.txt =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestWithURLRequirements(t *testing.T) {
+	old := []byte("# deps\nrequests==2.31.0\ngit+https://github.com/org/tool.git@v1#egg=tool\nlib @ https://example.com/lib-1.0.whl\nsvn+svn://svn.example.com/proj\n")
+	generated := []byte("requests==2.31.0\ntool==0.9\nsvn+svn://svn.example.com/proj\n")
+	got := string(withURLRequirements(generated, urlRequirements(old)))
+	want := "requests==2.31.0\nsvn+svn://svn.example.com/proj\ngit+https://github.com/org/tool.git@v1#egg=tool\nlib @ https://example.com/lib-1.0.whl\n"
+	if got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestCanonicalName(t *testing.T) {
+	tests := map[string]string{
+		"Flask-RESTful":      "flask-restful",
+		"zope.interface":     "zope-interface",
+		"typing_extensions":  "typing-extensions",
+		"Sphinx__RTD.-Theme": "sphinx-rtd-theme",
+		"PyYAML":             "pyyaml",
+		"requests":           "requests",
+	}
+	for in, want := range tests {
+		if got := canonicalName(in); got != want {
+			t.Errorf("canonicalName(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestNormalizeRequirements(t *testing.T) {
+	reqs, err := parseRequirements([]byte("zope.interface==6.1\nFlask-RESTful>=0.3\nPyYAML[libyaml]==6.0.1\nBabel\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := string(formatRequirements(normalizeRequirements(reqs)))
+	want := "babel\nflask-restful>=0.3\npyyaml[libyaml]==6.0.1\nzope-interface==6.1\n"
+	if got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestUpdateRequirementsValidate(t *testing.T) {
+	fake := &fakeRunner{content: "requests==2.31.0\nbroken=1.0\n"}
+	dir := t.TempDir()
+	reqPath := filepath.Join(dir, "requirements.txt")
+	writeFile(t, reqPath, "flask==3.0.0\n")
+
+	err := UpdateRequirements(context.Background(), dir, Options{Runner: fake, Validate: true}).Err
+	if !errors.Is(err, ErrInvalidRequirements) {
+		t.Fatalf("err = %v, want ErrInvalidRequirements", err)
+	}
+	if !strings.Contains(err.Error(), "line 2") {
+		t.Errorf("error %q does not name the offending line", err)
+	}
+	if got, _ := os.ReadFile(reqPath); string(got) != "flask==3.0.0\n" {
+		t.Errorf("requirements.txt = %q; want original restored", got)
+	}
+}
+
+func TestUpdateRequirementsMaxRemovedPct(t *testing.T) {
+	const old = "flask==3.0.0\nrequests==2.31.0\nnumpy==1.26.0\n"
+	const kept = "flask==3.0.0\n"                      // 2 of 3 removed
+	const keptTwo = "flask==3.0.0\nrequests==2.31.0\n" // 1 of 3 removed
+	tests := []struct {
+		name      string
+		generated string
+		opts      Options
+		wantErr   bool
+	}{
+		{"over limit", kept, Options{MaxRemovedPct: 50}, true},
+		{"a third over 33%", keptTwo, Options{MaxRemovedPct: 33}, true},
+		{"a third within 34%", keptTwo, Options{MaxRemovedPct: 34}, false},
+		{"within limit", kept, Options{MaxRemovedPct: 80}, false},
+		{"force", kept, Options{MaxRemovedPct: 50, Force: true}, false},
+		{"disabled", kept, Options{}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := &fakeRunner{content: tt.generated}
+			dir := t.TempDir()
+			reqPath := filepath.Join(dir, "requirements.txt")
+			writeFile(t, reqPath, old)
+			tt.opts.Runner = fake
+
+			err := UpdateRequirements(context.Background(), dir, tt.opts).Err
+			if tt.wantErr {
+				if !errors.Is(err, ErrTooManyRemoved) {
+					t.Fatalf("err = %v, want ErrTooManyRemoved", err)
+				}
+				if got, _ := os.ReadFile(reqPath); string(got) != old {
+					t.Errorf("requirements.txt = %q; want original restored", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got, _ := os.ReadFile(reqPath); string(got) != tt.generated {
+				t.Errorf("requirements.txt = %q", got)
+			}
+		})
+	}
+}
@@ -0,0 +1,406 @@
+package pipreqs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/sync/errgroup"
+)
+
+// errAborted stops Run's worker group at the error limit.
+var errAborted = errors.New("aborted at the error limit")
+
+// DefaultConcurrency returns the number of pipreqs runs Run starts in
+// parallel when Config.Concurrency is not set: one per CPU.
+func DefaultConcurrency() int { return max(runtime.NumCPU(), 1) }
+
+// Config carries every setting for Run. The zero value regenerates
+// requirements.txt in the current directory only (MaxDepth 0), running up
+// to DefaultConcurrency() pipreqs at once and keeping backups alongside.
+type Config struct {
+	Root        string          // directory to scan; "." if empty
+	Roots       []string        // several directories to scan; overrides Root if not empty
+	Targets     []Target        // if not nil, processed instead of discovering any roots
+	Discover    DiscoverOptions // how each root is walked
+	Update      Options         // per-target settings; Filename is filled in by Run
+	Concurrency int             // parallel pipreqs runs; DefaultConcurrency() if < 1
+
+	// ChangedSince, if set, is a git ref: only targets with a Python
+	// source changed since it (git diff --name-only) below their directory
+	// are processed. Targets outside a git repository are all processed.
+	ChangedSince string
+
+	Logger *slog.Logger // progress messages, each target at debug level; discarded if nil
+
+	// FailFast stops the run at the first failure: running targets are
+	// cancelled, restoring their backups, and queued ones are skipped.
+	FailFast bool
+
+	// MaxErrors, if positive, stops the run the same way once that many
+	// targets have failed. FailFast is MaxErrors 1.
+	MaxErrors int
+
+	// State, if set, makes the run incremental: targets whose Python
+	// sources and requirements file are unchanged since State last recorded
+	// them are skipped, unless Update.Force is set. Run records each target
+	// it regenerates successfully; dry runs leave State untouched.
+	State *State
+
+	// Cache, if set, supplies pipreqs' output for directories whose
+	// SourceHash it has seen, instead of running pipreqs, and records the
+	// output of every other run.
+	Cache *Cache
+
+	// OnStart, if set, is called with the sorted targets before any of them
+	// is processed.
+	OnStart func([]Target)
+
+	// StartTarget, if set, is called as each target starts, from the
+	// worker processing it, so calls may overlap. The target runs under the
+	// returned context, and the returned function is called with its Result
+	// as it finishes. It lets callers trace or time targets without Run
+	// depending on how.
+	StartTarget func(ctx context.Context, t Target) (context.Context, func(Result))
+
+	// OnResult, if set, is called with each Result as its target finishes,
+	// in completion order. Calls come from a single goroutine, so OnResult
+	// may write output without further locking; Run returns only after the
+	// last call has completed.
+	OnResult func(Result)
+}
+
+// Result is the outcome of processing a single requirements file.
+type Result struct {
+	Index    int // position in the sorted target list, regardless of completion order
+	Dir      string
+	Filename string
+	Changed  bool
+
+	Added   []string // packages in the new file but not the old one
+	Removed []string // packages in the old file but not the new one
+
+	Diff       string        // unified diff of the change when Options.Diff is set
+	Preview    []byte        // in a dry run, the content a changed file would get
+	LinkTarget string        // the file regenerated in place of a symlinked requirements file
+	BackupPath string        // backup left behind by this run; empty if none
+	Attempts   int           // pipreqs invocations; more than 1 after retries
+	Duration   time.Duration // wall time spent in UpdateRequirements
+	Cache      string        // CacheHit or CacheMiss when Config.Cache was consulted
+	Command    []string      // pipreqs executable and arguments, without the --savepath of its temporary output
+	Err        error
+}
+
+// countChanges sets Added and Removed by comparing the packages in the old
+// and new file contents.
+func (r *Result) countChanges(old, new []byte) {
+	r.Added, r.Removed = packageDiff(old, new)
+}
+
+// Path returns the requirements file's full path.
+func (r Result) Path() string { return filepath.Join(r.Dir, r.Filename) }
+
+// Skipped reports whether the directory was deliberately not processed.
+func (r Result) Skipped() bool { return errors.Is(r.Err, ErrSkip) }
+
+// Failed reports whether processing the directory failed.
+func (r Result) Failed() bool { return r.Err != nil && !r.Skipped() }
+
+// PermissionDenied reports whether the directory failed because its files
+// could not be written; see ErrPermission.
+func (r Result) PermissionDenied() bool { return errors.Is(r.Err, ErrPermission) }
+
+// Summary aggregates the results of a run.
+type Summary struct {
+	Processed int
+	Updated   int
+	Errors    int
+	Skipped   int
+	Results   []Result
+
+	PermissionErrors int            // failures that are PermissionDenied, also counted in Errors
+	Categories       map[string]int // failures by Result.Category; nil if none failed
+
+	Aborted   bool // the run was stopped early by its error limit
+	AbortedBy int  // Index of the result whose failure stopped the run
+	MaxErrors int  // the error limit that stopped the run
+
+	Elapsed time.Duration // wall time of the whole run, set by Run
+
+	CacheHits   int // results whose pipreqs output came from Config.Cache
+	CacheMisses int // results that ran pipreqs with Config.Cache set
+
+	// Conflicts are packages pinned differently across the requirements
+	// files, as found by Aggregate or FindConflicts, for reporting; Run
+	// leaves it empty.
+	Conflicts []Conflict
+}
+
+// Slowest returns the result that took longest, or false if there are no
+// results.
+func (s Summary) Slowest() (Result, bool) {
+	if len(s.Results) == 0 {
+		return Result{}, false
+	}
+	slowest := s.Results[0]
+	for _, r := range s.Results[1:] {
+		if r.Duration > slowest.Duration {
+			slowest = r
+		}
+	}
+	return slowest, true
+}
+
+// Summarize counts results by outcome.
+func Summarize(results []Result) Summary {
+	s := Summary{Processed: len(results), Results: results}
+	for _, r := range results {
+		switch r.Cache {
+		case CacheHit:
+			s.CacheHits++
+		case CacheMiss:
+			s.CacheMisses++
+		}
+		switch {
+		case r.Skipped():
+			s.Skipped++
+		case r.Err != nil:
+			s.Errors++
+			if r.PermissionDenied() {
+				s.PermissionErrors++
+			}
+			if s.Categories == nil {
+				s.Categories = map[string]int{}
+			}
+			s.Categories[r.Category()]++
+		case r.Changed:
+			s.Updated++
+		}
+	}
+	return s
+}
+
+// FindTargets returns the requirements files Run would process for cfg,
+// in processing order.
+func FindTargets(cfg Config) ([]Target, error) {
+	logger := cfg.Logger
+	if logger == nil {
+		logger = slog.New(slog.DiscardHandler)
+	}
+	targets, err := collectTargets(cfg, logger)
+	return targets, err
+}
+
+// roots returns the directories to scan.
+func (cfg Config) roots() []string {
+	if len(cfg.Roots) > 0 {
+		return cfg.Roots
+	}
+	if cfg.Root == "" {
+		return []string{"."}
+	}
+	return []string{cfg.Root}
+}
+
+// collectTargets discovers the targets below cfg's roots, or takes
+// cfg.Targets, and returns them sorted and deduplicated.
+func collectTargets(cfg Config, logger *slog.Logger) ([]Target, error) {
+	roots := cfg.roots()
+	var targets []Target
+	seen := map[Target]bool{} // real targets, so aliased directories run once
+	add := func(found []Target) {
+		for _, t := range found {
+			real := realTarget(t)
+			if seen[real] {
+				continue
+			}
+			seen[real] = true
+			targets = append(targets, t)
+		}
+	}
+	if cfg.Targets != nil {
+		add(cfg.Targets)
+		roots = nil
+	}
+	for _, root := range roots {
+		found, err := FindRequirementsDirs(root, cfg.Discover)
+		if err != nil {
+			return nil, err
+		}
+		if len(found) == 0 {
+			names := cfg.Discover.names()
+			logger.Info("no requirements file found; running pipreqs in root", "root", root, "filenames", strings.Join(names, ","))
+			for _, name := range names {
+				found = append(found, Target{Dir: root, Filename: name})
+			}
+		}
+		add(found)
+	}
+
+	if cfg.ChangedSince != "" {
+		var err error
+		if targets, err = filterChanged(targets, cfg.ChangedSince, cfg.Update.ScanNotebooks, logger); err != nil {
+			return nil, err
+		}
+	}
+
+	// deterministic processing order
+	SortTargets(targets)
+	return targets, nil
+}
+
+// errSourcesUnchanged marks a target skipped by an incremental run.
+var errSourcesUnchanged = fmt.Errorf("%w: sources unchanged since the last run", ErrSkip)
+
+// runTarget regenerates t, consulting and updating state and cache when
+// they are not nil.
+func runTarget(ctx context.Context, t Target, opts Options, state *State, cache *Cache) Result {
+	if state == nil && cache == nil {
+		return UpdateRequirements(ctx, t.Dir, opts)
+	}
+	path, err := filepath.Abs(t.Path())
+	if err != nil {
+		return Result{Dir: t.Dir, Filename: t.Filename, Err: err}
+	}
+	sources, err := SourceHash(t.Dir, opts)
+	if err != nil {
+		// without a hash the target cannot be tracked, but it can still run
+		if state != nil {
+			state.forget(path)
+		}
+		return UpdateRequirements(ctx, t.Dir, opts)
+	}
+	if cache != nil {
+		opts.cache, opts.cacheKey = cache, sources
+	}
+	if state == nil {
+		return UpdateRequirements(ctx, t.Dir, opts)
+	}
+	if !opts.Force && state.unchanged(path, sources) {
+		if cache != nil {
+			cache.touch(sources)
+		}
+		return Result{Dir: t.Dir, Filename: t.Filename, Err: errSourcesUnchanged}
+	}
+	r := UpdateRequirements(ctx, t.Dir, opts)
+	switch {
+	case opts.DryRun:
+	case r.Err != nil:
+		state.forget(path)
+	default:
+		state.record(path, sources)
+	}
+	return r
+}
+
+// Run discovers the requirements files below cfg.Root (or each of
+// cfg.Roots) and regenerates each of them once, even if several roots
+// overlap. When none are found below a root, the configured file names are
+// regenerated in that root itself. Per-file failures are reported in the
+// Summary; the error is for problems that stop the run before any file is
+// processed.
+func Run(ctx context.Context, cfg Config) (Summary, error) {
+	start := time.Now()
+	logger := cfg.Logger
+	if logger == nil {
+		logger = slog.New(slog.DiscardHandler)
+	}
+	targets, err := collectTargets(cfg, logger)
+	if err != nil {
+		return Summary{}, err
+	}
+
+	logger.Info("discovered requirements files to process", "count", len(targets))
+	for i, t := range targets {
+		logger.Debug("target", "index", i+1, "path", t.Path())
+	}
+	return runTargets(ctx, cfg, targets, start), nil
+}
+
+// runTargets regenerates targets under cfg and summarizes the run as
+// started at start.
+func runTargets(ctx context.Context, cfg Config, targets []Target, start time.Time) Summary {
+	update := cfg.Update
+	concurrency := cfg.Concurrency
+	if concurrency < 1 {
+		concurrency = DefaultConcurrency()
+	}
+
+	if cfg.OnStart != nil {
+		cfg.OnStart(targets)
+	}
+
+	maxErrors := cfg.MaxErrors
+	if cfg.FailFast {
+		maxErrors = 1
+	}
+
+	// the group's context is cancelled when a worker reaches the error
+	// limit; queued targets then see it as done and skip
+	g, runCtx := errgroup.WithContext(ctx)
+	g.SetLimit(concurrency)
+	var errorCount atomic.Int64
+	abortedBy := -1
+
+	// a single goroutine collects finished results and hands them to
+	// OnResult so that concurrent workers never write output themselves
+	results := make([]Result, len(targets))
+	finished := make(chan Result)
+	drained := make(chan struct{})
+	go func() {
+		defer close(drained)
+		for r := range finished {
+			results[r.Index] = r
+			if cfg.OnResult != nil {
+				cfg.OnResult(r)
+			}
+		}
+	}()
+
+	for i, t := range targets {
+		g.Go(func() error {
+			// targets still queued when ctx ends are skipped, not failed
+			if err := runCtx.Err(); err != nil {
+				finished <- Result{Index: i, Dir: t.Dir, Filename: t.Filename, Err: fmt.Errorf("%w: %w", ErrSkip, err)}
+				return nil
+			}
+
+			opts := update
+			opts.Filename = t.Filename
+			targetCtx, done := runCtx, func(Result) {}
+			if cfg.StartTarget != nil {
+				targetCtx, done = cfg.StartTarget(runCtx, t)
+			}
+			r := runTarget(targetCtx, t, opts, cfg.State, cfg.Cache)
+			r.Index = i
+			done(r)
+			finished <- r
+			// failures caused by an outside cancellation do not count; only
+			// the failure that reaches the limit sees it exactly
+			if maxErrors > 0 && r.Failed() && ctx.Err() == nil {
+				if errorCount.Add(1) == int64(maxErrors) {
+					abortedBy = i
+					return errAborted
+				}
+			}
+			return nil
+		})
+	}
+	// the only error a worker returns is errAborted, recorded in abortedBy
+	_ = g.Wait()
+	close(finished)
+	<-drained
+
+	s := Summarize(results)
+	s.Elapsed = time.Since(start)
+	if abortedBy >= 0 {
+		s.Aborted, s.AbortedBy, s.MaxErrors = true, abortedBy, maxErrors
+	}
+	return s
+}
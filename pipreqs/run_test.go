@@ -0,0 +1,295 @@
+package pipreqs
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRun(t *testing.T) {
+	root := t.TempDir()
+	makeTree(t, root, "a/requirements.txt", "a/app.py", "b/requirements.txt", "c/requirements.txt", "c/app.py")
+	writeFile(t, filepath.Join(root, "c", "requirements.txt"), "requests==2.31.0\n")
+	fake := &fakeRunner{content: "requests==2.31.0\n"}
+	var reported []string
+	started := -1
+
+	s, err := Run(context.Background(), Config{
+		Root:        root,
+		Discover:    DiscoverOptions{MaxDepth: 1},
+		Update:      Options{RequirePython: true, Runner: fake},
+		Concurrency: 1,
+		OnStart:     func(targets []Target) { started = len(targets) },
+		OnResult:    func(r Result) { reported = append(reported, filepath.Base(r.Dir)) },
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if started != 3 {
+		t.Errorf("OnStart saw %d targets, want 3", started)
+	}
+	if want := []string{"a", "b", "c"}; !reflect.DeepEqual(reported, want) {
+		t.Errorf("OnResult saw %q, want %q", reported, want)
+	}
+	if s.Processed != 3 || s.Updated != 1 || s.Skipped != 1 || s.Errors != 0 {
+		t.Errorf("summary = %+v, want 3 processed, 1 updated, 1 skipped", s)
+	}
+	if got := s.Results[1]; got.Dir != filepath.Join(root, "b") || !got.Skipped() {
+		t.Errorf("results[1] = %+v, want b skipped", got)
+	}
+	for i, r := range s.Results {
+		if r.Index != i {
+			t.Errorf("results[%d].Index = %d", i, r.Index)
+		}
+	}
+}
+
+func TestRunStartTarget(t *testing.T) {
+	root := t.TempDir()
+	makeTree(t, root, "a/requirements.txt", "a/app.py", "b/requirements.txt", "b/app.py")
+	type key struct{}
+	var seen []string
+	fake := RunnerFunc(func(ctx context.Context, bin string, args []string, dir string) ([]byte, error) {
+		if ctx.Value(key{}) != filepath.Base(dir) {
+			t.Errorf("pipreqs for %s ran under %v, want the context from StartTarget", dir, ctx.Value(key{}))
+		}
+		return nil, os.WriteFile(savePath(args, dir), []byte("requests==2.31.0\n"), 0o644)
+	})
+	_, err := Run(context.Background(), Config{
+		Root:        root,
+		Discover:    DiscoverOptions{MaxDepth: 1},
+		Update:      Options{Runner: fake},
+		Concurrency: 1,
+		StartTarget: func(ctx context.Context, tg Target) (context.Context, func(Result)) {
+			name := filepath.Base(tg.Dir)
+			return context.WithValue(ctx, key{}, name), func(r Result) {
+				if r.Dir != tg.Dir || !r.Changed {
+					t.Errorf("%s finished with %+v", name, r)
+				}
+				seen = append(seen, name)
+			}
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"a", "b"}; !reflect.DeepEqual(seen, want) {
+		t.Errorf("finished %q, want %q", seen, want)
+	}
+}
+
+func TestFindTargets(t *testing.T) {
+	root := t.TempDir()
+	makeTree(t, root, "b/requirements.txt", "a/requirements.txt", "a/deep/er/requirements.txt")
+
+	targets, err := FindTargets(Config{Root: root, Discover: DiscoverOptions{MaxDepth: 1}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := relDirs(t, root, targetDirs(targets)), []string{"a", "b"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("FindTargets = %q, want %q", got, want)
+	}
+}
+
+func TestRunFallsBackToRoot(t *testing.T) {
+	root := t.TempDir()
+	fake := &fakeRunner{content: "requests==2.31.0\n"}
+
+	s, err := Run(context.Background(), Config{Root: root, Update: Options{Runner: fake}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(s.Results) != 1 || s.Results[0].Dir != root || !s.Results[0].Changed {
+		t.Errorf("results = %+v, want root regenerated", s.Results)
+	}
+}
+
+func TestRunCancelledSkipsQueued(t *testing.T) {
+	root := t.TempDir()
+	makeTree(t, root, "a/requirements.txt", "b/requirements.txt")
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	s, err := Run(ctx, Config{Root: root, Discover: DiscoverOptions{MaxDepth: 1}, Update: Options{Runner: &fakeRunner{}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Skipped != 2 || s.Errors != 0 {
+		t.Errorf("summary = %+v, want 2 skipped, 0 errors", s)
+	}
+	for _, r := range s.Results {
+		if !errors.Is(r.Err, context.Canceled) {
+			t.Errorf("%s: err = %v, want context.Canceled", r.Dir, r.Err)
+		}
+	}
+}
+
+func TestRunMultipleRoots(t *testing.T) {
+	parent := t.TempDir()
+	makeTree(t, parent, "one/requirements.txt", "one/sub/requirements.txt", "two/requirements.txt")
+	fake := &fakeRunner{content: "requests==2.31.0\n"}
+
+	// one/sub overlaps with one and must only be processed once
+	roots := []string{filepath.Join(parent, "one"), filepath.Join(parent, "two"), filepath.Join(parent, "one", "sub")}
+	s, err := Run(context.Background(), Config{Roots: roots, Discover: DiscoverOptions{MaxDepth: 2}, Update: Options{Runner: fake}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, r := range s.Results {
+		rel, _ := filepath.Rel(parent, r.Dir)
+		got = append(got, filepath.ToSlash(rel))
+	}
+	if want := []string{"one", "one/sub", "two"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("processed %q, want %q", got, want)
+	}
+	if s.Processed != 3 || s.Updated != 3 {
+		t.Errorf("summary = %+v, want 3 processed and updated", s)
+	}
+}
+
+func TestRunExplicitTargets(t *testing.T) {
+	root := t.TempDir()
+	makeTree(t, root, "a/requirements.txt", "b/requirements.txt")
+	fake := &fakeRunner{content: "requests==2.31.0\n"}
+	a := Target{Dir: filepath.Join(root, "a"), Filename: DefaultFilename}
+
+	s, err := Run(context.Background(), Config{Root: root, Targets: []Target{a, a}, Update: Options{Runner: fake}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(s.Results) != 1 || s.Results[0].Dir != a.Dir {
+		t.Errorf("results = %+v, want only %s", s.Results, a.Dir)
+	}
+}
+
+func TestRunDedupsAliasedRoots(t *testing.T) {
+	parent := t.TempDir()
+	makeTree(t, parent, "app/requirements.txt")
+	app, alias := filepath.Join(parent, "app"), filepath.Join(parent, "alias")
+	if err := os.Symlink(app, alias); err != nil {
+		t.Skip("symlinks not supported:", err)
+	}
+	fake := &fakeRunner{content: "requests==2.31.0\n"}
+
+	// the same directory via a symlink and via ".."
+	roots := []string{app, alias, filepath.Join(alias, "..", "app")}
+	s, err := Run(context.Background(), Config{Roots: roots, Update: Options{Runner: fake}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(s.Results) != 1 || len(fake.calls) != 1 {
+		t.Errorf("processed %d results with %d pipreqs runs, want 1", len(s.Results), len(fake.calls))
+	}
+}
+
+func TestRunFailFast(t *testing.T) {
+	root := t.TempDir()
+	makeTree(t, root, "a/requirements.txt", "b/requirements.txt", "c/requirements.txt")
+	for _, d := range []string{"a", "b", "c"} {
+		writeFile(t, filepath.Join(root, d, "requirements.txt"), "flask==3.0.0\n")
+	}
+	fake := &fakeRunner{content: "requests==2.31.0\n"}
+	runner := RunnerFunc(func(ctx context.Context, bin string, args []string, workDir string) ([]byte, error) {
+		if filepath.Base(workDir) == "a" {
+			return []byte("wrong python"), errors.New("exit status 1")
+		}
+		return fake.Run(ctx, bin, args, workDir)
+	})
+
+	s, err := Run(context.Background(), Config{
+		Root:        root,
+		Discover:    DiscoverOptions{MaxDepth: 1},
+		Update:      Options{Runner: runner},
+		Concurrency: 1,
+		FailFast:    true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !s.Aborted || s.AbortedBy != 0 {
+		t.Errorf("aborted = %v by %d, want aborted by result 0", s.Aborted, s.AbortedBy)
+	}
+	if s.Errors != 1 || s.Skipped != 2 || len(fake.calls) != 0 {
+		t.Errorf("summary = %+v after %d further pipreqs runs; want 1 error, 2 skipped, none run", s, len(fake.calls))
+	}
+	for _, d := range []string{"a", "b", "c"} {
+		if got, _ := os.ReadFile(filepath.Join(root, d, "requirements.txt")); string(got) != "flask==3.0.0\n" {
+			t.Errorf("%s/requirements.txt = %q, want original", d, got)
+		}
+	}
+}
+
+func TestRunMaxErrors(t *testing.T) {
+	root := t.TempDir()
+	makeTree(t, root, "a/requirements.txt", "b/requirements.txt", "c/requirements.txt", "d/requirements.txt")
+	var runs atomic.Int32
+	runner := RunnerFunc(func(ctx context.Context, bin string, args []string, workDir string) ([]byte, error) {
+		runs.Add(1)
+		return nil, errors.New("exit status 1")
+	})
+
+	s, err := Run(context.Background(), Config{
+		Root:        root,
+		Discover:    DiscoverOptions{MaxDepth: 1},
+		Update:      Options{Runner: runner},
+		Concurrency: 1,
+		MaxErrors:   2,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !s.Aborted || s.AbortedBy != 1 || s.MaxErrors != 2 {
+		t.Errorf("aborted = %v by %d at limit %d, want aborted by result 1 at limit 2", s.Aborted, s.AbortedBy, s.MaxErrors)
+	}
+	if s.Errors != 2 || s.Skipped != 2 || runs.Load() != 2 {
+		t.Errorf("summary = %+v after %d pipreqs runs; want 2 errors, 2 skipped, 2 runs", s, runs.Load())
+	}
+}
+
+func TestSummarySlowest(t *testing.T) {
+	if _, ok := (Summary{}).Slowest(); ok {
+		t.Error("Slowest of an empty summary reported a result")
+	}
+	s := Summarize([]Result{
+		{Index: 0, Duration: time.Second},
+		{Index: 1, Duration: 3 * time.Second},
+		{Index: 2, Duration: 2 * time.Second},
+	})
+	if r, ok := s.Slowest(); !ok || r.Index != 1 {
+		t.Errorf("Slowest = %d, %v; want result 1", r.Index, ok)
+	}
+}
+
+func TestRunBackupDirSeveralRoots(t *testing.T) {
+	a, b := t.TempDir(), t.TempDir()
+	writeFile(t, filepath.Join(a, "requirements.txt"), "alpha==1\n")
+	writeFile(t, filepath.Join(b, "requirements.txt"), "beta==1\n")
+	backupDir := t.TempDir()
+
+	s, err := Run(context.Background(), Config{
+		Roots:  []string{a, b},
+		Update: Options{BackupDir: backupDir, BackupSuffix: ".bak", Runner: &fakeRunner{content: "requests==2.31.0\n"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Updated != 2 || s.Errors != 0 {
+		t.Fatalf("summary = %+v, want both roots updated", s)
+	}
+	for root, want := range map[string]string{a: "alpha==1\n", b: "beta==1\n"} {
+		opts := Options{Root: root, BackupDir: backupDir, BackupSuffix: ".bak"}
+		backup := filepath.Join(opts.BackupRoot(), "requirements.txt.bak")
+		if got, err := os.ReadFile(backup); err != nil || string(got) != want {
+			t.Errorf("backup of %s = %q, %v; want %q", root, got, err, want)
+		}
+		if got, want := opts.OriginalPath(backup), filepath.Join(root, "requirements.txt"); got != want {
+			t.Errorf("OriginalPath(%s) = %s, want %s", backup, got, want)
+		}
+	}
+}
@@ -0,0 +1,121 @@
+package pipreqs
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"sync"
+	"time"
+)
+
+// Runner runs an external command in dir and returns its combined output,
+// or as much of its end as the runner keeps.
+// Tests substitute a fake to avoid depending on a real pipreqs install.
+type Runner interface {
+	Run(ctx context.Context, bin string, args []string, dir string) ([]byte, error)
+}
+
+// RunnerFunc adapts an ordinary function to the Runner interface.
+type RunnerFunc func(ctx context.Context, bin string, args []string, dir string) ([]byte, error)
+
+// Run calls f.
+func (f RunnerFunc) Run(ctx context.Context, bin string, args []string, dir string) ([]byte, error) {
+	return f(ctx, bin, args, dir)
+}
+
+// cmdWaitDelay bounds how long ExecRunner waits for I/O after a cancelled
+// command has been killed.
+const cmdWaitDelay = 5 * time.Second
+
+// DefaultMaxOutput is how many bytes of a command's output ExecRunner keeps
+// when MaxOutput is not set. Only the end of a long traceback matters for an
+// error report, and many pipreqs may be running at once.
+const DefaultMaxOutput = 64 << 10
+
+// ExecRunner runs commands with os/exec. The process is killed if ctx is
+// cancelled.
+type ExecRunner struct {
+	Env       []string // environment for the command; the current one if nil
+	MaxOutput int      // bytes of output kept, the last ones; DefaultMaxOutput if < 1
+}
+
+// Run runs bin in dir. If the command fails, the error is an *OutputError
+// holding its stdout and stderr apart; the returned output interleaves both.
+func (r ExecRunner) Run(ctx context.Context, bin string, args []string, dir string) ([]byte, error) {
+	cmd := exec.CommandContext(ctx, bin, args...)
+	// don't wait forever on output pipes held open by pipreqs' own children
+	// once the process itself has been killed
+	cmd.WaitDelay = cmdWaitDelay
+	cmd.Dir = dir
+	cmd.Env = r.Env
+	if cmd.Env == nil {
+		cmd.Env = os.Environ()
+	}
+	limit := r.MaxOutput
+	if limit < 1 {
+		limit = DefaultMaxOutput
+	}
+	out := &tailBuffer{max: limit}
+	stdout, stderr := &tailBuffer{max: limit}, &tailBuffer{max: limit}
+	cmd.Stdout = io.MultiWriter(out, stdout)
+	cmd.Stderr = io.MultiWriter(out, stderr)
+	if err := cmd.Run(); err != nil {
+		return out.Bytes(), &OutputError{Err: err, Stdout: stdout.Bytes(), Stderr: stderr.Bytes()}
+	}
+	return out.Bytes(), nil
+}
+
+// OutputError is a failed command's error along with the ends of its
+// stdout and stderr.
+type OutputError struct {
+	Err    error
+	Stdout []byte
+	Stderr []byte
+}
+
+func (e *OutputError) Error() string { return e.Err.Error() }
+
+func (e *OutputError) Unwrap() error { return e.Err }
+
+// tailBuffer is an io.Writer that keeps only the last max bytes written,
+// holding at most twice that in memory. It is safe for concurrent writes.
+type tailBuffer struct {
+	mu      sync.Mutex
+	max     int
+	buf     []byte
+	dropped int64
+}
+
+func (b *tailBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	n := len(p)
+	if len(p) > b.max {
+		b.dropped += int64(len(p) - b.max)
+		p = p[len(p)-b.max:]
+	}
+	b.buf = append(b.buf, p...)
+	if over := len(b.buf) - b.max; over > 0 && len(b.buf) >= 2*b.max {
+		b.dropped += int64(over)
+		b.buf = append(b.buf[:0], b.buf[over:]...)
+	}
+	return n, nil
+}
+
+// Bytes returns the kept output, preceded by a note of how much was
+// dropped, if any.
+func (b *tailBuffer) Bytes() []byte {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	out := b.buf
+	if over := len(out) - b.max; over > 0 {
+		out = out[over:]
+	}
+	dropped := b.dropped + int64(len(b.buf)-len(out))
+	if dropped == 0 {
+		return out
+	}
+	return append([]byte(fmt.Sprintf("[%d earlier bytes of output omitted]\n", dropped)), out...)
+}
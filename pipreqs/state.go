@@ -0,0 +1,180 @@
+package pipreqs
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// stateVersion is the manifest format written by State.Save.
+const stateVersion = 1
+
+// State is the manifest of an incremental run: for each requirements file,
+// the hash of the Python sources it was last generated from and of the file
+// itself. It is safe for concurrent use.
+type State struct {
+	mu      sync.Mutex
+	entries map[string]stateEntry // keyed by absolute requirements file path
+}
+
+type stateEntry struct {
+	Sources      string `json:"sources"`
+	Requirements string `json:"requirements"`
+}
+
+type stateFile struct {
+	Version int                   `json:"version"`
+	Entries map[string]stateEntry `json:"entries"`
+}
+
+// NewState returns an empty manifest.
+func NewState() *State {
+	return &State{entries: map[string]stateEntry{}}
+}
+
+// LoadState reads the manifest at path. A missing file is an empty manifest.
+func LoadState(path string) (*State, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return NewState(), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var f stateFile
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	if f.Version != stateVersion {
+		return nil, fmt.Errorf("%s: unsupported state version %d", path, f.Version)
+	}
+	s := NewState()
+	for k, e := range f.Entries {
+		s.entries[k] = e
+	}
+	return s, nil
+}
+
+// Save writes the manifest to path, replacing it atomically.
+func (s *State) Save(path string) error {
+	s.mu.Lock()
+	data, err := json.MarshalIndent(stateFile{Version: stateVersion, Entries: s.entries}, "", "  ")
+	s.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	return WriteFileAtomic(path, append(data, '\n'), 0o644)
+}
+
+// Len returns the number of requirements files recorded.
+func (s *State) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.entries)
+}
+
+// unchanged reports whether the requirements file at path was last
+// generated from sources and has not been edited or removed since.
+func (s *State) unchanged(path, sources string) bool {
+	s.mu.Lock()
+	e, ok := s.entries[path]
+	s.mu.Unlock()
+	if !ok || e.Sources != sources {
+		return false
+	}
+	sum, err := FileHash(path)
+	return err == nil && sum == e.Requirements
+}
+
+// record remembers that the requirements file at path was generated from
+// sources.
+func (s *State) record(path, sources string) {
+	sum, err := FileHash(path)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err != nil {
+		delete(s.entries, path)
+		return
+	}
+	s.entries[path] = stateEntry{Sources: sources, Requirements: sum}
+}
+
+// forget drops the entry for path, so the next run processes it.
+func (s *State) forget(path string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.entries, path)
+}
+
+// SourceHash returns a hex-encoded SHA-256 over the Python files pipreqs
+// would scan in dir (their relative paths and contents), the options that
+// shape its output, and the pipreqs that produces it (executable,
+// interpreter, version, and with PreferVenv the virtualenv it runs in), so
+// a change to any of them yields a different hash.
+func SourceHash(dir string, opts Options) (string, error) {
+	var files []string
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if path != dir && slices.Contains(pythonIgnoreDirs, d.Name()) {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		ext := filepath.Ext(d.Name())
+		if strings.EqualFold(ext, ".py") || (opts.ScanNotebooks && strings.EqualFold(ext, ".ipynb")) {
+			files = append(files, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+
+	h := sha256.New()
+	fmt.Fprintf(h, "mode=%q encoding=%q args=%q header=%t merge=%t vcs=%t normalize=%t notebooks=%t\n",
+		opts.Mode, opts.Encoding, opts.ExtraArgs, opts.PreserveHeader, opts.Merge, opts.PreserveVCS, opts.Normalize, opts.ScanNotebooks)
+	venv := ""
+	if opts.PreferVenv {
+		if v := findVenv(dir); v != "" {
+			venv = venvFingerprint(v)
+		}
+	}
+	fmt.Fprintf(h, "pipreqs=%q python=%q version=%q prefer-venv=%t venv=%q\n",
+		opts.Pipreqs, opts.Python, opts.PipreqsVersion, opts.PreferVenv, venv)
+	for _, path := range files {
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return "", err
+		}
+		f, err := os.Open(path)
+		if err != nil {
+			return "", err
+		}
+		info, err := f.Stat()
+		if err != nil {
+			f.Close()
+			return "", err
+		}
+		// the length prefix keeps one file's content from running into the
+		// next file's name
+		fmt.Fprintf(h, "%s\x00%d\x00", filepath.ToSlash(rel), info.Size())
+		_, err = io.Copy(h, f)
+		f.Close()
+		if err != nil {
+			return "", err
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
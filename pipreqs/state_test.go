@@ -0,0 +1,120 @@
+package pipreqs
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+)
+
+func TestRunState(t *testing.T) {
+	root := t.TempDir()
+	makeTree(t, root, "a/requirements.txt", "a/app.py", "b/requirements.txt", "b/app.py")
+	manifest := filepath.Join(t.TempDir(), "state.json")
+	fake := &fakeRunner{content: "requests==2.31.0\n"}
+	run := func(state *State, opts Options) Summary {
+		t.Helper()
+		opts.Runner = fake
+		s, err := Run(context.Background(), Config{Root: root, Discover: DiscoverOptions{MaxDepth: 1}, Update: opts, Concurrency: 1, State: state})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return s
+	}
+
+	state, err := LoadState(manifest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := run(state, Options{}); s.Skipped != 0 || len(fake.calls) != 2 {
+		t.Fatalf("first run: summary = %+v, %d pipreqs calls", s, len(fake.calls))
+	}
+	if err := state.Save(manifest); err != nil {
+		t.Fatal(err)
+	}
+
+	state, err = LoadState(manifest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if state.Len() != 2 {
+		t.Fatalf("reloaded state has %d entries, want 2", state.Len())
+	}
+	writeFile(t, filepath.Join(root, "b", "app.py"), "import requests\n")
+	fake.calls = nil
+	s := run(state, Options{})
+	if s.Skipped != 1 || !s.Results[0].Skipped() || s.Results[1].Skipped() || len(fake.calls) != 1 {
+		t.Errorf("second run: summary = %+v, %d pipreqs calls; want only b regenerated", s, len(fake.calls))
+	}
+
+	// editing the requirements file by hand invalidates its entry
+	writeFile(t, filepath.Join(root, "a", "requirements.txt"), "flask\n")
+	fake.calls = nil
+	if s := run(state, Options{}); s.Skipped != 1 || s.Results[0].Skipped() || len(fake.calls) != 1 {
+		t.Errorf("after edit: summary = %+v, %d pipreqs calls; want only a regenerated", s, len(fake.calls))
+	}
+
+	fake.calls = nil
+	if s := run(state, Options{Force: true}); s.Skipped != 0 || len(fake.calls) != 2 {
+		t.Errorf("forced: summary = %+v, %d pipreqs calls; want both regenerated", s, len(fake.calls))
+	}
+}
+
+func TestSourceHash(t *testing.T) {
+	dir := t.TempDir()
+	makeTree(t, dir, "app.py", "pkg/mod.py", "venv/lib.py", "notes.ipynb")
+	base, err := SourceHash(dir, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	same := func(opts Options) bool {
+		t.Helper()
+		h, err := SourceHash(dir, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return h == base
+	}
+
+	writeFile(t, filepath.Join(dir, "venv", "lib.py"), "import ignored\n")
+	writeFile(t, filepath.Join(dir, "notes.ipynb"), "{}\n")
+	if !same(Options{}) {
+		t.Error("hash changed with ignored or non-Python files")
+	}
+	if same(Options{ScanNotebooks: true}) {
+		t.Error("hash did not change when notebooks are scanned")
+	}
+	if same(Options{Mode: "no-pin"}) {
+		t.Error("hash did not change with --mode")
+	}
+	if same(Options{PipreqsVersion: "0.5.0"}) || same(Options{Pipreqs: "/opt/pipreqs"}) || same(Options{Python: "python3.12"}) {
+		t.Error("hash did not change with another pipreqs")
+	}
+
+	// the virtualenv pipreqs runs in counts once PreferVenv uses it
+	writeFile(t, filepath.Join(dir, "venv", "pyvenv.cfg"), "version = 3.11.9\n")
+	venvHash := func() string {
+		t.Helper()
+		h, err := SourceHash(dir, Options{PreferVenv: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return h
+	}
+	before := venvHash()
+	if before == base || !same(Options{}) {
+		t.Error("hash did not change with PreferVenv only")
+	}
+	writeFile(t, filepath.Join(dir, "venv", "pyvenv.cfg"), "version = 3.12.4\n")
+	after := venvHash()
+	if after == before {
+		t.Error("hash did not change with the venv's interpreter")
+	}
+	makeTree(t, dir, "venv/lib/python3.12/site-packages/pipreqs-0.5.0.dist-info/METADATA")
+	if venvHash() == after {
+		t.Error("hash did not change with the venv's pipreqs")
+	}
+	writeFile(t, filepath.Join(dir, "pkg", "mod.py"), "import requests\n")
+	if same(Options{}) {
+		t.Error("hash did not change with a source file")
+	}
+}
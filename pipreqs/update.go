@@ -0,0 +1,585 @@
+// Package pipreqs discovers requirements files in a directory tree and
+// regenerates them with pipreqs, keeping backups of the files it replaces.
+package pipreqs
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// Options carries the per-directory settings for UpdateRequirements.
+type Options struct {
+	DryRun    bool
+	Mode      string   // pipreqs --mode; empty leaves pipreqs' default
+	Encoding  string   // pipreqs --encoding; empty leaves pipreqs' default
+	ExtraArgs []string // raw arguments appended after the built-in ones
+
+	KeepBackups bool // keep the .bak even when the content is unchanged
+	NoBackup    bool // replace the file without keeping a backup
+
+	BackupSuffix string // appended to the backup file name; ".bak" if empty
+	BackupDir    string // if set, backups mirror each file's absolute path under this directory
+	Root         string // absolute root whose backups BackupRoot locates
+
+	Filename string // requirements file to regenerate; DefaultFilename if empty
+
+	// FollowLinks regenerates the file a symlinked requirements file points
+	// to, keeping the link; without it such a file is refused.
+	FollowLinks bool
+
+	RequirePython bool // skip directories that contain no .py files
+	ScanNotebooks bool // feed imports from .ipynb files to pipreqs
+
+	PreserveHeader bool // carry the old file's leading comment block over
+	Merge          bool // keep old specifiers for packages in both old and new
+	PreserveVCS    bool // re-append git+/URL lines pipreqs cannot detect
+	Normalize      bool // PEP 503 names, sorted
+	Validate       bool // reject output that does not parse
+
+	MaxRemovedPct int  // reject output dropping more than this share of packages; 0 disables
+	SkipDirty     bool // skip files with uncommitted changes in their git repository
+	Force         bool // skip safety checks such as MaxRemovedPct and SkipDirty
+
+	Diff bool // record a unified diff of each change in Result.Diff
+
+	// Confirm, if set, is asked before a changed file replaces the
+	// original, with a Result whose Diff shows the change. If it returns
+	// false the original and its backup are left as they were and the
+	// directory is reported skipped with ErrDeclined. Dry runs never ask.
+	Confirm func(Result) bool
+
+	// ChangeDetection is how Result.Changed is decided: ChangeHash (the
+	// default) compares hashes of the contents, ChangeSize compares sizes
+	// and then the contents without hashing. Either way a rewrite with
+	// identical content is not a change.
+	ChangeDetection string
+	HashAlgorithm   string // for ChangeHash: HashSHA256 if empty, HashXXHash, or HashCRC32
+
+	Pipreqs    string // pipreqs executable name or path; "pipreqs" if empty
+	Python     string // if set, run pipreqs as a module of this interpreter
+	PreferVenv bool   // run pipreqs inside a .venv or venv found in the directory
+
+	// PipreqsVersion is the version of the pipreqs being run, if known. It
+	// only feeds SourceHash, so an upgrade invalidates recorded state.
+	PipreqsVersion string
+
+	Timeout    time.Duration // limit on each pipreqs invocation; 0 means none
+	Retries    int           // extra attempts after pipreqs itself fails
+	RetryDelay time.Duration // pause between attempts
+
+	Runner Runner // runs pipreqs; ExecRunner if nil
+
+	// PreHook and PostHook are shell commands run in the directory before
+	// and after it is regenerated, with QUICK_PIPREQS_DIR and
+	// QUICK_PIPREQS_FILE set (and QUICK_PIPREQS_CHANGED for PostHook). A
+	// failing PreHook skips the directory; a failing PostHook fails it.
+	// PostHook only runs after a successful update. Dry runs run neither.
+	PreHook  string
+	PostHook string
+	// HookOutput, if set, receives the hooks' output as they run, one Write
+	// per line, prefixed with the requirements file and the hook. Hooks of
+	// different directories may run at once, so it must be safe for
+	// concurrent use.
+	HookOutput io.Writer
+
+	// set by Run when Config.Cache is set: the cache and this directory's
+	// SourceHash
+	cache    *Cache
+	cacheKey string
+}
+
+// runner returns the Runner to invoke pipreqs with.
+func (o Options) runner() Runner {
+	if o.Runner == nil {
+		return ExecRunner{}
+	}
+	return o.Runner
+}
+
+// PipreqsCommand returns the executable and arguments that run pipreqs
+// with args.
+func (o Options) PipreqsCommand(args ...string) (string, []string) {
+	switch {
+	case o.Python != "":
+		// the pipreqs package has no __main__, so "-m pipreqs" fails
+		return o.Python, append([]string{"-m", "pipreqs.pipreqs"}, args...)
+	case o.Pipreqs != "":
+		return o.Pipreqs, args
+	}
+	return "pipreqs", args
+}
+
+// generate produces pipreqs' output for dir: from the cache if it holds
+// o.cacheKey, otherwise by running pipreqs, caching what it writes.
+func (o Options) generate(ctx context.Context, args []string, dir string, res *Result, reset func()) ([]byte, error) {
+	if o.cache == nil {
+		return o.runPipreqs(ctx, args, dir, res, reset)
+	}
+	target := savePath(args, dir)
+	if content, ok := o.cache.get(o.cacheKey); ok {
+		res.Cache = CacheHit
+		return nil, os.WriteFile(target, content, 0o644)
+	}
+	res.Cache = CacheMiss
+	out, err := o.runPipreqs(ctx, args, dir, res, reset)
+	if err != nil {
+		return out, err
+	}
+	if content, err := os.ReadFile(target); err == nil {
+		o.cache.put(o.cacheKey, content)
+	}
+	return out, nil
+}
+
+// savePath returns the file pipreqs writes when run in dir with args.
+func savePath(args []string, dir string) string {
+	target := DefaultFilename
+	for i, a := range args {
+		if a == "--savepath" && i+1 < len(args) {
+			target = args[i+1]
+		}
+	}
+	if !filepath.IsAbs(target) {
+		target = filepath.Join(dir, target)
+	}
+	return target
+}
+
+// runPipreqs invokes pipreqs in dir, retrying up to o.Retries times when
+// pipreqs ran and exited with an error. Cancellation, timeouts and failures
+// to start pipreqs at all, such as a missing executable, are not retried.
+// reset, if not nil, is called before each retry to clear partial output.
+// The number of attempts is recorded in res.
+func (o Options) runPipreqs(ctx context.Context, args []string, dir string, res *Result, reset func()) ([]byte, error) {
+	for attempt := 1; ; attempt++ {
+		res.Attempts = attempt
+		out, err := o.runPipreqsOnce(ctx, args, dir)
+		var exitErr *exec.ExitError
+		if err == nil || attempt > o.Retries || ctx.Err() != nil || errors.Is(err, ErrTimeout) || !errors.As(err, &exitErr) {
+			return out, err
+		}
+		if reset != nil {
+			reset()
+		}
+		t := time.NewTimer(o.RetryDelay)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return out, err
+		case <-t.C:
+		}
+	}
+}
+
+// invocation returns the Runner, executable and arguments that run pipreqs
+// with args in dir, resolving a virtualenv when PreferVenv is set.
+func (o Options) invocation(args []string, dir string) (Runner, string, []string) {
+	runner := o.runner()
+	bin, args := o.PipreqsCommand(args...)
+	if o.PreferVenv {
+		runner, bin = venvRunner(dir, runner, bin, o.Pipreqs == "" && o.Python == "")
+	}
+	return runner, bin, args
+}
+
+// runPipreqsOnce invokes pipreqs in dir, bounded by o.Timeout if set.
+func (o Options) runPipreqsOnce(ctx context.Context, args []string, dir string) ([]byte, error) {
+	runner, bin, args := o.invocation(args, dir)
+	if o.Timeout <= 0 {
+		return runner.Run(ctx, bin, args, dir)
+	}
+	runCtx, cancel := context.WithTimeout(ctx, o.Timeout)
+	defer cancel()
+	out, err := runner.Run(runCtx, bin, args, dir)
+	if err != nil && ctx.Err() == nil && errors.Is(runCtx.Err(), context.DeadlineExceeded) {
+		err = fmt.Errorf("%w after %s", ErrTimeout, o.Timeout)
+	}
+	return out, err
+}
+
+// venvRunner adapts runner and bin to the virtualenv in dir, if any: the
+// venv's own pipreqs replaces bin when replaceBin is set, and an ExecRunner
+// gets the activated environment. Other runners are used unchanged.
+func venvRunner(dir string, runner Runner, bin string, replaceBin bool) (Runner, string) {
+	venv := findVenv(dir)
+	if venv == "" {
+		return runner, bin
+	}
+	if p := venvPipreqs(venv); p != "" && replaceBin {
+		bin = p
+	}
+	if er, ok := runner.(ExecRunner); ok {
+		er.Env = venvEnv(venv)
+		runner = er
+	}
+	return runner, bin
+}
+
+// pipreqsError describes a failed pipreqs invocation, distinguishing
+// cancellation and timeouts from pipreqs' own failures. pipreqs' output
+// follows, with stderr and stdout labelled when the runner kept them apart.
+func pipreqsError(ctx context.Context, err error, out []byte) error {
+	switch {
+	case ctx.Err() != nil:
+		return fmt.Errorf("pipreqs cancelled: %w", ctx.Err())
+	case errors.Is(err, ErrTimeout):
+		return err
+	}
+	var oe *OutputError
+	if !errors.As(err, &oe) {
+		return fmt.Errorf("%w: %w\n%s", ErrPipreqsFailed, err, string(out))
+	}
+	var sb strings.Builder
+	for _, s := range []struct {
+		label  string
+		output []byte
+	}{{"stderr", oe.Stderr}, {"stdout", oe.Stdout}} {
+		if len(bytes.TrimSpace(s.output)) > 0 {
+			fmt.Fprintf(&sb, "%s:\n%s", s.label, strings.TrimRight(string(s.output), "\n")+"\n")
+		}
+	}
+	return fmt.Errorf("%w: %w\n%s", ErrPipreqsFailed, err, sb.String())
+}
+
+// ErrPipreqsFailed wraps the error of a pipreqs invocation that did not
+// succeed, together with its captured output.
+var ErrPipreqsFailed = errors.New("pipreqs failed")
+
+// ErrTimeout is returned when pipreqs runs longer than Options.Timeout.
+var ErrTimeout = errors.New("pipreqs timed out")
+
+// ErrPermission marks a directory whose requirements file, backup, or
+// temporary output could not be written because of file permissions or a
+// read-only filesystem.
+var ErrPermission = errors.New("permission denied")
+
+// permissionError wraps err in ErrPermission if it stems from file
+// permissions or a read-only filesystem.
+func permissionError(err error) error {
+	if err == nil || errors.Is(err, ErrPermission) {
+		return err
+	}
+	if errors.Is(err, fs.ErrPermission) || errors.Is(err, syscall.EROFS) {
+		return fmt.Errorf("%w: %w", ErrPermission, err)
+	}
+	return err
+}
+
+// ErrSkip marks a directory that was deliberately not processed. It is
+// reported as skipped rather than failed.
+var ErrSkip = errors.New("skipped")
+
+// ErrDeclined marks a change that Options.Confirm turned down.
+var ErrDeclined = fmt.Errorf("%w: change declined", ErrSkip)
+
+// pythonIgnoreDirs mirrors the directories pipreqs itself ignores when
+// scanning for imports.
+var pythonIgnoreDirs = []string{".hg", ".svn", ".git", ".tox", "__pycache__", "env", "venv", ".venv", ".ipynb_checkpoints"}
+
+// hasPythonFiles reports whether dir or any directory below it that pipreqs
+// would scan contains a .py file, or a .ipynb file when notebooks is set.
+func hasPythonFiles(dir string, notebooks bool) (bool, error) {
+	found := false
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if path != dir && slices.Contains(pythonIgnoreDirs, d.Name()) {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		ext := filepath.Ext(d.Name())
+		if strings.EqualFold(ext, ".py") || (notebooks && strings.EqualFold(ext, ".ipynb")) {
+			found = true
+			return fs.SkipAll
+		}
+		return nil
+	})
+	return found, err
+}
+
+// Name returns the requirements file name to regenerate.
+func (o Options) Name() string {
+	if o.Filename == "" {
+		return DefaultFilename
+	}
+	return o.Filename
+}
+
+// ValidMode reports whether mode is an accepted pipreqs --mode value.
+// An empty mode means pipreqs' default pinning.
+func ValidMode(mode string) bool {
+	switch mode {
+	case "", "no-pin", "gt", "compat":
+		return true
+	}
+	return false
+}
+
+// UpdateRequirements regenerates the requirements file in dir with pipreqs
+// and reports the outcome. The existing file is copied to its backup first;
+// the new content is written to a temporary file and renamed into place
+// only if pipreqs and post-processing succeed, so a failure leaves the
+// original untouched.
+func UpdateRequirements(ctx context.Context, dir string, opts Options) Result {
+	start := time.Now()
+	res := Result{Dir: dir, Filename: opts.Name()}
+	err := opts.withHooks(ctx, dir, &res, func() error {
+		return updateRequirements(ctx, dir, opts, &res)
+	})
+	if err == nil && res.BackupPath != "" {
+		// stamped only now, so that edits by the post-hook are part of
+		// the output restore tells apart from later manual edits
+		reqPath := res.Path()
+		if res.LinkTarget != "" {
+			reqPath = res.LinkTarget
+		}
+		err = stampBackup(reqPath, res.BackupPath)
+	}
+	res.Err = permissionError(err)
+	res.Duration = time.Since(start)
+	return res
+}
+
+// updateRequirements does the work of UpdateRequirements, recording what it
+// did in res.
+func updateRequirements(ctx context.Context, dir string, opts Options, res *Result) error {
+	reqPath := filepath.Join(dir, opts.Name())
+	backupPath := opts.BackupPath(reqPath)
+
+	// replacing a symlink with a regular file would silently detach it from
+	// the file it shares
+	if info, err := os.Lstat(reqPath); err == nil && info.Mode()&fs.ModeSymlink != 0 {
+		if !opts.FollowLinks {
+			return fmt.Errorf("%s is a symlink and following links is turned off (--no-follow-symlinks)", opts.Name())
+		}
+		real, err := filepath.EvalSymlinks(reqPath)
+		if err != nil {
+			return fmt.Errorf("resolving symlink: %w", err)
+		}
+		res.LinkTarget, reqPath = real, real
+	}
+
+	if opts.SkipDirty && !opts.Force {
+		dirty, err := gitDirty(reqPath)
+		if err != nil {
+			return err
+		}
+		if dirty {
+			// regenerating would discard edits git has no copy of
+			return fmt.Errorf("%w: %s has uncommitted changes; commit them or pass --force", ErrSkip, opts.Name())
+		}
+	}
+
+	if opts.RequirePython {
+		ok, err := hasPythonFiles(dir, opts.ScanNotebooks)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			// pipreqs would blank out the file
+			return fmt.Errorf("%w: no .py files", ErrSkip)
+		}
+	}
+
+	args := []string{"."}
+	if opts.Mode != "" {
+		args = append(args, "--mode", opts.Mode)
+	}
+	if opts.Encoding != "" {
+		args = append(args, "--encoding", opts.Encoding)
+	}
+	args = append(args, opts.ExtraArgs...)
+
+	_, bin, cmdArgs := opts.invocation(args, dir)
+	res.Command = append([]string{bin}, cmdArgs...)
+
+	if opts.ScanNotebooks {
+		// pipreqs still runs in dir, for its venv, but scans the mirror
+		scanDir, cleanup, err := notebookScanDir(dir)
+		defer cleanup()
+		if err != nil {
+			return fmt.Errorf("extracting notebook imports: %w", err)
+		}
+		if scanDir != dir {
+			args = append([]string{scanDir}, args[1:]...)
+		}
+	}
+
+	if opts.DryRun {
+		return previewRequirements(ctx, dir, reqPath, args, opts, res)
+	}
+	// pipreqs writes to a temporary file next to the original, which is
+	// renamed over it only once everything succeeded: the requirements
+	// file never goes missing, and a failure leaves it as it was
+	tmp, err := os.CreateTemp(filepath.Dir(reqPath), "."+filepath.Base(reqPath)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	tmpPath := tmp.Name()
+	tmp.Close()
+	defer os.Remove(tmpPath)
+	// pipreqs must create the file itself, or a partial run could not be
+	// told apart from one that wrote nothing
+	if err := os.Remove(tmpPath); err != nil {
+		return err
+	}
+	args = append(args, "--savepath", tmpPath)
+
+	// copy the current file to its backup (replacing any older backup);
+	// the backup is dropped again if the run fails
+	var old []byte
+	var perm fs.FileMode = 0o644
+	preExists := false
+	backedUp := false
+	if info, err := os.Stat(reqPath); err == nil {
+		preExists = true
+		perm = info.Mode().Perm()
+		if old, err = os.ReadFile(reqPath); err != nil {
+			return err
+		}
+		if !opts.NoBackup {
+			if err := os.MkdirAll(filepath.Dir(backupPath), 0o755); err != nil {
+				return err
+			}
+			if err := writeBackup(reqPath, backupPath, int64(len(old))); err != nil {
+				return err
+			}
+			backedUp = true
+		}
+	}
+	fail := func(err error) error {
+		if backedUp {
+			_ = os.Remove(backupPath)
+		}
+		return err
+	}
+
+	reset := func() { _ = os.Remove(tmpPath) }
+	if out, err := opts.generate(ctx, args, dir, res, reset); err != nil {
+		return fail(pipreqsError(ctx, err, out))
+	}
+	if _, err := os.Stat(tmpPath); err != nil {
+		if preExists {
+			return fail(fmt.Errorf("pipreqs did not write %s", opts.Name()))
+		}
+		// nothing before, nothing after
+		return nil
+	}
+	if err := postProcess(tmpPath, old, opts); err != nil {
+		return fail(err)
+	}
+	out, err := os.ReadFile(tmpPath)
+	if err != nil {
+		return fail(err)
+	}
+	res.countChanges(old, out)
+	res.Changed = !preExists || opts.contentChanged(old, out)
+	switch {
+	case !res.Changed && backedUp && !opts.KeepBackups:
+		// identical output; the backup is just clutter
+		return os.Remove(backupPath)
+	case res.Changed:
+		if opts.Diff {
+			oldName := reqPath
+			if backedUp {
+				oldName = backupPath
+			}
+			res.Diff = unifiedDiff(oldName, reqPath, old, out)
+		}
+		if opts.Confirm != nil {
+			preview := *res
+			preview.Diff = unifiedDiff(reqPath, reqPath, old, out)
+			if !opts.Confirm(preview) {
+				res.Changed, res.Diff, res.Added, res.Removed = false, "", nil, nil
+				return fail(ErrDeclined)
+			}
+		}
+		if err := os.Chmod(tmpPath, perm); err != nil {
+			return fail(err)
+		}
+		if err := os.Rename(tmpPath, reqPath); err != nil {
+			return fail(err)
+		}
+	}
+	// identical output leaves the original file untouched
+	if backedUp {
+		res.BackupPath = backupPath
+	}
+	return nil
+}
+
+// previewRequirements runs pipreqs with its output redirected to a temporary
+// file and records in res whether reqPath would change. Neither reqPath nor
+// its backup is touched.
+func previewRequirements(ctx context.Context, dir, reqPath string, args []string, opts Options, res *Result) error {
+	tmpDir, err := os.MkdirTemp("", "quick_pipreqs-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmpDir)
+	tmpPath := filepath.Join(tmpDir, "requirements.txt")
+
+	args = append(append([]string(nil), args...), "--savepath", tmpPath)
+	if out, err := opts.generate(ctx, args, dir, res, nil); err != nil {
+		return pipreqsError(ctx, err, out)
+	}
+	if _, err := os.Stat(tmpPath); err != nil {
+		// pipreqs produced nothing; the real run would not write a file either
+		return nil
+	}
+	old, readErr := os.ReadFile(reqPath)
+	if err := postProcess(tmpPath, old, opts); err != nil {
+		return err
+	}
+	out, err := os.ReadFile(tmpPath)
+	if err != nil {
+		return err
+	}
+	res.countChanges(old, out)
+	if opts.Diff {
+		res.Diff = unifiedDiff(reqPath, reqPath, old, out)
+	}
+	res.Changed = readErr != nil || opts.contentChanged(old, out)
+	if res.Changed {
+		res.Preview = out
+	}
+	return nil
+}
+
+// FileHash returns the hex-encoded SHA-256 of the file at path.
+func FileHash(path string) (string, error) {
+	return FileHashWith(path, HashSHA256)
+}
+
+// FileHashWith returns the hex-encoded hash of the file at path using
+// algorithm, one of HashSHA256 (also used if empty), HashXXHash, and
+// HashCRC32.
+func FileHashWith(path, algorithm string) (string, error) {
+	h, err := newHash(algorithm)
+	if err != nil {
+		return "", err
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%x", h.Sum(nil)), nil
+}
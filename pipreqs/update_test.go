@@ -0,0 +1,653 @@
+package pipreqs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeRunner stands in for pipreqs. Each call records its args and writes
+// content as the generated requirements.txt, or to the --savepath target
+// when one is given.
+type fakeRunner struct {
+	content string
+	calls   [][]string
+}
+
+func (f *fakeRunner) Run(ctx context.Context, bin string, args []string, workDir string) ([]byte, error) {
+	f.calls = append(f.calls, append([]string(nil), args...))
+	return nil, os.WriteFile(savePath(args, workDir), []byte(f.content), 0o644)
+}
+
+// withoutSavepath returns args without the --savepath of the temporary file
+// UpdateRequirements has pipreqs write to.
+func withoutSavepath(args []string) []string {
+	if i := slices.Index(args, "--savepath"); i >= 0 && i+1 < len(args) {
+		return slices.Delete(slices.Clone(args), i, i+2)
+	}
+	return args
+}
+
+func TestUpdateRequirementsArgs(t *testing.T) {
+	tests := []struct {
+		name string
+		opts Options
+		want []string
+	}{
+		{"defaults", Options{}, []string{"."}},
+		{"mode", Options{Mode: "gt"}, []string{".", "--mode", "gt"}},
+		{"encoding", Options{Encoding: "latin-1"}, []string{".", "--encoding", "latin-1"}},
+		{"mode and encoding", Options{Mode: "compat", Encoding: "utf-16"}, []string{".", "--mode", "compat", "--encoding", "utf-16"}},
+		{"passthrough keeps order", Options{Mode: "gt", ExtraArgs: []string{"--proxy", "http://p:3128", "--clean", "req.txt"}}, []string{".", "--mode", "gt", "--proxy", "http://p:3128", "--clean", "req.txt"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := &fakeRunner{content: "requests==2.31.0\n"}
+			tt.opts.Runner = fake
+			dirs := []string{t.TempDir(), t.TempDir()}
+			for _, dir := range dirs {
+				if err := UpdateRequirements(context.Background(), dir, tt.opts).Err; err != nil {
+					t.Fatalf("UpdateRequirements(%s): %v", dir, err)
+				}
+			}
+			if len(fake.calls) != len(dirs) {
+				t.Fatalf("got %d pipreqs calls, want %d", len(fake.calls), len(dirs))
+			}
+			for _, got := range fake.calls {
+				if got := withoutSavepath(got); !reflect.DeepEqual(got, tt.want) {
+					t.Errorf("args = %q, want %q", got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestUpdateRequirementsPipreqsCommand(t *testing.T) {
+	tests := []struct {
+		name     string
+		opts     Options
+		wantBin  string
+		wantArgs []string
+	}{
+		{"default", Options{}, "pipreqs", []string{"."}},
+		{"pipreqs path", Options{Pipreqs: "/opt/venv/bin/pipreqs"}, "/opt/venv/bin/pipreqs", []string{"."}},
+		{"python module", Options{Python: "python3", Mode: "gt"}, "python3", []string{"-m", "pipreqs.pipreqs", ".", "--mode", "gt"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var gotBin string
+			fake := &fakeRunner{content: "requests==2.31.0\n"}
+			tt.opts.Runner = RunnerFunc(func(ctx context.Context, bin string, args []string, workDir string) ([]byte, error) {
+				gotBin = bin
+				return fake.Run(ctx, bin, args, workDir)
+			})
+			if err := UpdateRequirements(context.Background(), t.TempDir(), tt.opts).Err; err != nil {
+				t.Fatal(err)
+			}
+			if got := withoutSavepath(fake.calls[0]); gotBin != tt.wantBin || !reflect.DeepEqual(got, tt.wantArgs) {
+				t.Errorf("ran %q %q, want %q %q", gotBin, got, tt.wantBin, tt.wantArgs)
+			}
+		})
+	}
+}
+
+func TestUpdateRequirementsDryRun(t *testing.T) {
+	fake := &fakeRunner{content: "requests==2.31.0\n"}
+	tests := []struct {
+		name     string
+		existing string // empty means no requirements.txt
+		want     bool
+	}{
+		{"missing", "", true},
+		{"stale", "flask==3.0.0\n", true},
+		{"current", "requests==2.31.0\n", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			reqPath := filepath.Join(dir, "requirements.txt")
+			if tt.existing != "" {
+				writeFile(t, reqPath, tt.existing)
+			}
+			res := UpdateRequirements(context.Background(), dir, Options{Runner: fake, DryRun: true})
+			if res.Err != nil {
+				t.Fatal(res.Err)
+			}
+			if res.Changed != tt.want {
+				t.Errorf("changed = %v, want %v", res.Changed, tt.want)
+			}
+			got, err := os.ReadFile(reqPath)
+			if tt.existing == "" {
+				if !os.IsNotExist(err) {
+					t.Errorf("dry run created %s", reqPath)
+				}
+			} else if string(got) != tt.existing {
+				t.Errorf("dry run modified requirements.txt: %q", got)
+			}
+			if _, err := os.Stat(reqPath + ".bak"); !os.IsNotExist(err) {
+				t.Errorf("dry run created a backup")
+			}
+		})
+	}
+}
+
+func TestUpdateRequirementsConfirm(t *testing.T) {
+	for _, accept := range []bool{true, false} {
+		dir := t.TempDir()
+		reqPath := filepath.Join(dir, "requirements.txt")
+		writeFile(t, reqPath, "flask==3.0.0\n")
+		var asked Result
+		confirm := func(r Result) bool { asked = r; return accept }
+		res := UpdateRequirements(context.Background(), dir, Options{Runner: &fakeRunner{content: "requests==2.31.0\n"}, Confirm: confirm})
+		if !strings.Contains(asked.Diff, "+requests==2.31.0") {
+			t.Errorf("accept=%v: Confirm got diff %q", accept, asked.Diff)
+		}
+		got, _ := os.ReadFile(reqPath)
+		_, bakErr := os.Stat(reqPath + ".bak")
+		if accept {
+			if res.Err != nil || !res.Changed || string(got) != "requests==2.31.0\n" || bakErr != nil {
+				t.Errorf("accepted: result %+v, requirements.txt %q, backup err %v", res, got, bakErr)
+			}
+			continue
+		}
+		if !errors.Is(res.Err, ErrDeclined) || !res.Skipped() || res.Changed {
+			t.Errorf("declined: result %+v, want skipped with ErrDeclined", res)
+		}
+		if string(got) != "flask==3.0.0\n" || !os.IsNotExist(bakErr) {
+			t.Errorf("declined: requirements.txt %q, backup err %v; want the original and no backup", got, bakErr)
+		}
+	}
+}
+
+func TestUpdateRequirementsDryRunCommand(t *testing.T) {
+	fake := &fakeRunner{content: "requests==2.31.0\n"}
+	opts := Options{Runner: fake, DryRun: true, Pipreqs: "/opt/bin/pipreqs", Mode: "gt", Encoding: "latin-1", ExtraArgs: []string{"--clean", "req.txt"}}
+	res := UpdateRequirements(context.Background(), t.TempDir(), opts)
+	if res.Err != nil {
+		t.Fatal(res.Err)
+	}
+	want := []string{"/opt/bin/pipreqs", ".", "--mode", "gt", "--encoding", "latin-1", "--clean", "req.txt"}
+	if !reflect.DeepEqual(res.Command, want) {
+		t.Errorf("command = %q, want %q", res.Command, want)
+	}
+	if got := withoutSavepath(fake.calls[0]); !reflect.DeepEqual(got, want[1:]) {
+		t.Errorf("ran %q, want the planned %q", got, want[1:])
+	}
+}
+
+func TestUpdateRequirementsCancelRestoresBackup(t *testing.T) {
+	dir := t.TempDir()
+	reqPath := filepath.Join(dir, "requirements.txt")
+	writeFile(t, reqPath, "flask==3.0.0\n")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	fake := RunnerFunc(func(ctx context.Context, bin string, args []string, workDir string) ([]byte, error) {
+		// partial output, then interrupted
+		writeFile(t, savePath(args, workDir), "req")
+		cancel()
+		return nil, ctx.Err()
+	})
+
+	if err := UpdateRequirements(ctx, dir, Options{Runner: fake}).Err; !errors.Is(err, context.Canceled) {
+		t.Fatalf("err = %v, want context.Canceled", err)
+	}
+	if got, err := os.ReadFile(reqPath); err != nil || string(got) != "flask==3.0.0\n" {
+		t.Errorf("requirements.txt = %q, %v; want original content", got, err)
+	}
+	if _, err := os.Stat(reqPath + ".bak"); !os.IsNotExist(err) {
+		t.Errorf("backup left behind after cancel")
+	}
+}
+
+func TestUpdateRequirementsTimeout(t *testing.T) {
+	dir := t.TempDir()
+	reqPath := filepath.Join(dir, "requirements.txt")
+	writeFile(t, reqPath, "flask==3.0.0\n")
+
+	hang := RunnerFunc(func(ctx context.Context, bin string, args []string, workDir string) ([]byte, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	})
+	err := UpdateRequirements(context.Background(), dir, Options{Runner: hang, Timeout: 10 * time.Millisecond}).Err
+	if !errors.Is(err, ErrTimeout) {
+		t.Fatalf("err = %v, want ErrTimeout", err)
+	}
+	if got, err := os.ReadFile(reqPath); err != nil || string(got) != "flask==3.0.0\n" {
+		t.Errorf("requirements.txt = %q, %v; want original content", got, err)
+	}
+}
+
+func TestUpdateRequirementsRetries(t *testing.T) {
+	tests := []struct {
+		name         string
+		failures     int
+		retries      int
+		wantErr      bool
+		wantAttempts int
+	}{
+		{"no retries", 1, 0, true, 1},
+		{"recovers", 2, 2, false, 3},
+		{"gives up", 3, 2, true, 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			reqPath := filepath.Join(dir, "requirements.txt")
+			writeFile(t, reqPath, "flask==3.0.0\n")
+
+			calls := 0
+			flaky := RunnerFunc(func(ctx context.Context, bin string, args []string, workDir string) ([]byte, error) {
+				calls++
+				out := savePath(args, workDir)
+				if _, err := os.Stat(out); err == nil {
+					return nil, errors.New("leftover output from an earlier attempt")
+				}
+				if calls <= tt.failures {
+					// partial output, which a retry must clear
+					writeFile(t, out, "req")
+					return []byte("ConnectionError"), &exec.ExitError{}
+				}
+				writeFile(t, out, "requests==2.31.0\n")
+				return nil, nil
+			})
+			res := UpdateRequirements(context.Background(), dir, Options{Runner: flaky, Retries: tt.retries})
+			if (res.Err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, wantErr %v", res.Err, tt.wantErr)
+			}
+			if res.Attempts != tt.wantAttempts {
+				t.Errorf("attempts = %d, want %d", res.Attempts, tt.wantAttempts)
+			}
+			want := "requests==2.31.0\n"
+			if tt.wantErr {
+				want = "flask==3.0.0\n"
+			}
+			if got, _ := os.ReadFile(reqPath); string(got) != want {
+				t.Errorf("requirements.txt = %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestUpdateRequirementsNotFoundNotRetried(t *testing.T) {
+	dir := t.TempDir()
+	missing := RunnerFunc(func(ctx context.Context, bin string, args []string, workDir string) ([]byte, error) {
+		return nil, &exec.Error{Name: bin, Err: exec.ErrNotFound}
+	})
+	res := UpdateRequirements(context.Background(), dir, Options{Runner: missing, Retries: 3, RetryDelay: time.Hour})
+	if !errors.Is(res.Err, exec.ErrNotFound) {
+		t.Fatalf("err = %v, want exec.ErrNotFound", res.Err)
+	}
+	if res.Attempts != 1 {
+		t.Errorf("attempts = %d, want 1", res.Attempts)
+	}
+}
+
+func TestUpdateRequirementsRetryDelayCancelled(t *testing.T) {
+	dir := t.TempDir()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	failing := RunnerFunc(func(ctx context.Context, bin string, args []string, workDir string) ([]byte, error) {
+		cancel()
+		return nil, &exec.ExitError{}
+	})
+
+	start := time.Now()
+	res := UpdateRequirements(ctx, dir, Options{Runner: failing, Retries: 5, RetryDelay: time.Hour})
+	if !errors.Is(res.Err, context.Canceled) {
+		t.Fatalf("err = %v, want context.Canceled", res.Err)
+	}
+	if res.Attempts != 1 || time.Since(start) > 10*time.Second {
+		t.Errorf("attempts = %d after %s; want a single attempt and no delay", res.Attempts, time.Since(start))
+	}
+}
+
+func TestExecRunnerKilledOnCancel(t *testing.T) {
+	if _, err := exec.LookPath("sleep"); err != nil {
+		t.Skip("sleep not available")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if _, err := (ExecRunner{}).Run(ctx, "sleep", []string{"30"}, t.TempDir()); err == nil {
+		t.Fatal("expected an error from a cancelled command")
+	}
+	if elapsed := time.Since(start); elapsed > 10*time.Second {
+		t.Errorf("Run took %s after cancel", elapsed)
+	}
+}
+
+func TestExecRunnerOutputTail(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh not available")
+	}
+	ctx := context.Background()
+	out, err := ExecRunner{MaxOutput: 8}.Run(ctx, "sh", []string{"-c", "printf 0123456789; printf abcdef"}, t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "[8 earlier bytes of output omitted]\n89abcdef"; string(out) != want {
+		t.Errorf("output = %q, want %q", out, want)
+	}
+
+	// the streams are read concurrently, so their interleaving in the
+	// combined output is not fixed; each one's own tail is
+	_, err = ExecRunner{MaxOutput: 8}.Run(ctx, "sh", []string{"-c", "printf 0123456789; printf abcdefghij >&2; exit 1"}, t.TempDir())
+	var oe *OutputError
+	if !errors.As(err, &oe) {
+		t.Fatalf("err = %v, want an *OutputError", err)
+	}
+	if want := "[2 earlier bytes of output omitted]\n23456789"; string(oe.Stdout) != want {
+		t.Errorf("stdout = %q, want %q", oe.Stdout, want)
+	}
+	if want := "[2 earlier bytes of output omitted]\ncdefghij"; string(oe.Stderr) != want {
+		t.Errorf("stderr = %q, want %q", oe.Stderr, want)
+	}
+}
+
+func TestExecRunnerSeparateStreams(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh not available")
+	}
+	ctx := context.Background()
+	out, err := ExecRunner{}.Run(ctx, "sh", []string{"-c", "echo scanning; echo Traceback >&2; exit 1"}, t.TempDir())
+	var oe *OutputError
+	if !errors.As(err, &oe) {
+		t.Fatalf("err = %v, want an *OutputError", err)
+	}
+	if string(oe.Stdout) != "scanning\n" || string(oe.Stderr) != "Traceback\n" {
+		t.Errorf("stdout = %q, stderr = %q", oe.Stdout, oe.Stderr)
+	}
+	if len(out) != len("scanning\nTraceback\n") {
+		t.Errorf("combined output = %q", out)
+	}
+	want := "pipreqs failed: exit status 1\nstderr:\nTraceback\nstdout:\nscanning\n"
+	if got := pipreqsError(ctx, err, out).Error(); got != want {
+		t.Errorf("pipreqsError = %q, want %q", got, want)
+	}
+}
+
+func TestTailBuffer(t *testing.T) {
+	b := &tailBuffer{max: 4}
+	for _, s := range []string{"ab", "cd", "e", "fghij", "k"} {
+		b.Write([]byte(s))
+		if len(b.buf) > 2*b.max {
+			t.Fatalf("buffer holds %d bytes, want at most %d", len(b.buf), 2*b.max)
+		}
+	}
+	if got, want := string(b.Bytes()), "[7 earlier bytes of output omitted]\nhijk"; got != want {
+		t.Errorf("Bytes() = %q, want %q", got, want)
+	}
+	if got := string((&tailBuffer{max: 4}).Bytes()); got != "" {
+		t.Errorf("empty Bytes() = %q", got)
+	}
+}
+
+func TestUpdateRequirementsFailureRestoresBackup(t *testing.T) {
+	tests := []struct {
+		name string
+		run  func(out string) ([]byte, error)
+	}{
+		{"pipreqs error", func(out string) ([]byte, error) {
+			return []byte("Traceback: boom"), errors.New("exit status 1")
+		}},
+		{"partial output then error", func(out string) ([]byte, error) {
+			if err := os.WriteFile(out, []byte("req"), 0o644); err != nil {
+				return nil, err
+			}
+			return nil, errors.New("exit status 1")
+		}},
+		{"no output", func(out string) ([]byte, error) {
+			return nil, nil
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			reqPath := filepath.Join(dir, "requirements.txt")
+			writeFile(t, reqPath, "flask==3.0.0\n")
+			fake := RunnerFunc(func(ctx context.Context, bin string, args []string, workDir string) ([]byte, error) {
+				// the original must stay in place while pipreqs runs
+				if got, err := os.ReadFile(reqPath); err != nil || string(got) != "flask==3.0.0\n" {
+					t.Errorf("during the run requirements.txt = %q, %v; want original content", got, err)
+				}
+				return tt.run(savePath(args, workDir))
+			})
+
+			if err := UpdateRequirements(context.Background(), dir, Options{Runner: fake}).Err; err == nil {
+				t.Fatal("expected an error")
+			}
+			if got, err := os.ReadFile(reqPath); err != nil || string(got) != "flask==3.0.0\n" {
+				t.Errorf("requirements.txt = %q, %v; want original content", got, err)
+			}
+			if _, err := os.Stat(reqPath + ".bak"); !os.IsNotExist(err) {
+				t.Errorf("backup left behind after failure")
+			}
+			if entries, _ := os.ReadDir(dir); len(entries) != 1 {
+				t.Errorf("directory holds %d entries after failure, want only requirements.txt", len(entries))
+			}
+		})
+	}
+}
+
+func TestUpdateRequirementsBackups(t *testing.T) {
+	tests := []struct {
+		name        string
+		existing    string
+		keepBackups bool
+		wantBackup  bool
+	}{
+		{"changed", "flask==3.0.0\n", false, true},
+		{"unchanged", "requests==2.31.0\n", false, false},
+		{"unchanged keep", "requests==2.31.0\n", true, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := &fakeRunner{content: "requests==2.31.0\n"}
+			dir := t.TempDir()
+			reqPath := filepath.Join(dir, "requirements.txt")
+			writeFile(t, reqPath, tt.existing)
+			if err := UpdateRequirements(context.Background(), dir, Options{Runner: fake, KeepBackups: tt.keepBackups}).Err; err != nil {
+				t.Fatal(err)
+			}
+			_, err := os.Stat(reqPath + ".bak")
+			if gotBackup := err == nil; gotBackup != tt.wantBackup {
+				t.Errorf("backup present = %v, want %v", gotBackup, tt.wantBackup)
+			}
+		})
+	}
+}
+
+func TestUpdateRequirementsNoBackup(t *testing.T) {
+	fake := &fakeRunner{content: "requests==2.31.0\n"}
+	dir := t.TempDir()
+	reqPath := filepath.Join(dir, "requirements.txt")
+	writeFile(t, reqPath, "flask==3.0.0\n")
+
+	res := UpdateRequirements(context.Background(), dir, Options{Runner: fake, NoBackup: true})
+	if res.Err != nil {
+		t.Fatal(res.Err)
+	}
+	if !res.Changed {
+		t.Error("changed = false, want true")
+	}
+	if _, err := os.Stat(reqPath + ".bak"); !os.IsNotExist(err) {
+		t.Error("--no-backup created a backup")
+	}
+	if got, _ := os.ReadFile(reqPath); string(got) != "requests==2.31.0\n" {
+		t.Errorf("requirements.txt = %q, want the new content", got)
+	}
+}
+
+func TestUpdateRequirementsBackupLocation(t *testing.T) {
+	root := t.TempDir()
+	backupRoot := t.TempDir()
+	tests := []struct {
+		name string
+		opts Options
+		want string // backup path relative to root, or to its mirror under opts.BackupDir
+	}{
+		{"suffix", Options{BackupSuffix: ".orig"}, "svc/api/requirements.txt.orig"},
+		{"dir", Options{BackupSuffix: ".bak", BackupDir: backupRoot}, "svc/api/requirements.txt.bak"},
+		{"dir without suffix", Options{BackupDir: backupRoot}, "svc/api/requirements.txt"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := &fakeRunner{content: "requests==2.31.0\n"}
+			dir := filepath.Join(root, "svc", "api")
+			makeTree(t, root, "svc/api/requirements.txt")
+			writeFile(t, filepath.Join(dir, "requirements.txt"), "flask==3.0.0\n")
+			tt.opts.Root = root
+			tt.opts.Runner = fake
+
+			if err := UpdateRequirements(context.Background(), dir, tt.opts).Err; err != nil {
+				t.Fatal(err)
+			}
+			base := tt.opts.BackupRoot()
+			backup := filepath.Join(base, filepath.FromSlash(tt.want))
+			if got, err := os.ReadFile(backup); err != nil || string(got) != "flask==3.0.0\n" {
+				t.Errorf("backup %s = %q, %v; want original content", backup, got, err)
+			}
+			if err := os.Remove(backup); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+func TestCustomFilename(t *testing.T) {
+	root := t.TempDir()
+	makeTree(t, root, "a/deps.txt", "b/requirements.txt", "c/DEPS.TXT")
+
+	dirs, err := FindRequirementsDirs(root, DiscoverOptions{MaxDepth: -1, Filenames: []string{"deps.txt"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := relDirs(t, root, targetDirs(dirs)), []string{"a", "c"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("dirs = %q, want %q", got, want)
+	}
+
+	fake := &fakeRunner{content: "requests==2.31.0\n"}
+	dir := filepath.Join(root, "a")
+	res := UpdateRequirements(context.Background(), dir, Options{Runner: fake, Filename: "deps.txt"})
+	if res.Err != nil {
+		t.Fatal(res.Err)
+	}
+	if !res.Changed {
+		t.Error("changed = false, want true")
+	}
+	if got, _ := os.ReadFile(filepath.Join(dir, "deps.txt")); string(got) != "requests==2.31.0\n" {
+		t.Errorf("deps.txt = %q, want the new content", got)
+	}
+	if got, err := os.ReadFile(filepath.Join(dir, "deps.txt.bak")); err != nil || len(got) != 0 {
+		t.Errorf("deps.txt.bak = %q, %v; want original (empty) content", got, err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "requirements.txt")); !os.IsNotExist(err) {
+		t.Error("requirements.txt was written alongside deps.txt")
+	}
+}
+
+func TestUpdateRequirementsRequirePythonFiles(t *testing.T) {
+	tests := []struct {
+		name     string
+		files    []string
+		wantSkip bool
+	}{
+		{"top level", []string{"app.py"}, false},
+		{"nested", []string{"pkg/mod.py"}, false},
+		{"none", []string{"README.md"}, true},
+		{"only in venv", []string{".venv/lib/site.py", "__pycache__/x.py"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := &fakeRunner{content: ""}
+			dir := t.TempDir()
+			makeTree(t, dir, tt.files...)
+			writeFile(t, filepath.Join(dir, "requirements.txt"), "vendored==1.0\n")
+
+			err := UpdateRequirements(context.Background(), dir, Options{Runner: fake, RequirePython: true}).Err
+			if gotSkip := errors.Is(err, ErrSkip); gotSkip != tt.wantSkip {
+				t.Fatalf("err = %v, want skip %v", err, tt.wantSkip)
+			}
+			if tt.wantSkip && len(fake.calls) != 0 {
+				t.Errorf("pipreqs ran %d times for a skipped directory", len(fake.calls))
+			}
+		})
+	}
+}
+
+func TestUpdateRequirementsResult(t *testing.T) {
+	for _, dryRun := range []bool{false, true} {
+		fake := &fakeRunner{content: "flask==3.0.0\nrequests==2.31.0\nnumpy==1.26.0\n"}
+		dir := t.TempDir()
+		reqPath := filepath.Join(dir, "requirements.txt")
+		writeFile(t, reqPath, "Flask==2.0.0\nurllib3==2.0.0\n")
+
+		res := UpdateRequirements(context.Background(), dir, Options{Runner: fake, DryRun: dryRun, Diff: true})
+		if res.Err != nil {
+			t.Fatal(res.Err)
+		}
+		if res.Dir != dir || res.Filename != DefaultFilename || !res.Changed {
+			t.Errorf("dry run %v: result = %+v", dryRun, res)
+		}
+		if want := []string{"numpy", "requests"}; !reflect.DeepEqual(res.Added, want) {
+			t.Errorf("dry run %v: added = %q, want %q", dryRun, res.Added, want)
+		}
+		if want := []string{"urllib3"}; !reflect.DeepEqual(res.Removed, want) {
+			t.Errorf("dry run %v: removed = %q, want %q", dryRun, res.Removed, want)
+		}
+		if !strings.Contains(res.Diff, "-urllib3==2.0.0\n") || !strings.Contains(res.Diff, "+numpy==1.26.0\n") {
+			t.Errorf("dry run %v: diff = %q", dryRun, res.Diff)
+		}
+		wantBackup := reqPath + ".bak"
+		if dryRun {
+			wantBackup = ""
+		}
+		if res.BackupPath != wantBackup {
+			t.Errorf("dry run %v: backup = %q, want %q", dryRun, res.BackupPath, wantBackup)
+		}
+	}
+}
+
+func TestUpdateRequirementsSymlink(t *testing.T) {
+	for _, follow := range []bool{false, true} {
+		t.Run(fmt.Sprintf("follow=%v", follow), func(t *testing.T) {
+			dir := t.TempDir()
+			shared := filepath.Join(t.TempDir(), "shared.txt")
+			writeFile(t, shared, "flask==3.0.0\n")
+			link := filepath.Join(dir, "requirements.txt")
+			if err := os.Symlink(shared, link); err != nil {
+				t.Skip("symlinks unsupported:", err)
+			}
+			fake := &fakeRunner{content: "requests==2.31.0\n"}
+			res := UpdateRequirements(context.Background(), dir, Options{Runner: fake, FollowLinks: follow, Force: true})
+
+			want := "flask==3.0.0\n"
+			if follow {
+				if res.Err != nil {
+					t.Fatal(res.Err)
+				}
+				if res.LinkTarget != shared || !res.Changed {
+					t.Errorf("result = %+v, want a change to %s", res, shared)
+				}
+				want = "requests==2.31.0\n"
+			} else if res.Err == nil || !strings.Contains(res.Err.Error(), "symlink") || len(fake.calls) != 0 {
+				t.Errorf("err = %v after %d pipreqs calls, want a symlink error before running pipreqs", res.Err, len(fake.calls))
+			}
+			if info, err := os.Lstat(link); err != nil || info.Mode()&os.ModeSymlink == 0 {
+				t.Errorf("requirements.txt is no longer a symlink: %v", err)
+			}
+			if got, _ := os.ReadFile(shared); string(got) != want {
+				t.Errorf("link target = %q, want %q", got, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,87 @@
+package pipreqs
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// venvNames are the virtualenv directory names looked for next to a
+// requirements file, in order of preference.
+var venvNames = []string{".venv", "venv"}
+
+// venvBinDir is the name of a virtualenv's executables directory.
+func venvBinDir() string {
+	if runtime.GOOS == "windows" {
+		return "Scripts"
+	}
+	return "bin"
+}
+
+// findVenv returns the virtualenv directly inside dir, or "" if there is
+// none. A directory counts as a virtualenv when it has a pyvenv.cfg.
+func findVenv(dir string) string {
+	for _, name := range venvNames {
+		venv := filepath.Join(dir, name)
+		if _, err := os.Stat(filepath.Join(venv, "pyvenv.cfg")); err == nil {
+			return venv
+		}
+	}
+	return ""
+}
+
+// venvFingerprint returns a description of venv that changes when it is
+// recreated with another interpreter or gets another pipreqs: its
+// pyvenv.cfg and the names of its installed pipreqs distributions, which
+// carry their versions.
+func venvFingerprint(venv string) string {
+	var b strings.Builder
+	if cfg, err := os.ReadFile(filepath.Join(venv, "pyvenv.cfg")); err == nil {
+		b.Write(cfg)
+	}
+	patterns := []string{filepath.Join(venv, "lib", "python*", "site-packages", "pipreqs-*.dist-info")}
+	if runtime.GOOS == "windows" {
+		patterns = []string{filepath.Join(venv, "Lib", "site-packages", "pipreqs-*.dist-info")}
+	}
+	for _, pattern := range patterns {
+		matches, _ := filepath.Glob(pattern)
+		for _, m := range matches {
+			b.WriteString("\n" + filepath.Base(m))
+		}
+	}
+	return b.String()
+}
+
+// venvEnv returns the current environment as activating venv would leave
+// it: VIRTUAL_ENV set, its executables first on PATH, and PYTHONHOME unset.
+func venvEnv(venv string) []string {
+	bin := filepath.Join(venv, venvBinDir())
+	path := bin
+	env := []string{"VIRTUAL_ENV=" + venv}
+	for _, kv := range os.Environ() {
+		key, value, _ := strings.Cut(kv, "=")
+		switch {
+		case strings.EqualFold(key, "PATH"):
+			path = bin + string(os.PathListSeparator) + value
+		case strings.EqualFold(key, "VIRTUAL_ENV"), strings.EqualFold(key, "PYTHONHOME"):
+		default:
+			env = append(env, kv)
+		}
+	}
+	return append(env, "PATH="+path)
+}
+
+// venvPipreqs returns the pipreqs executable installed in venv, or "" if
+// it has none.
+func venvPipreqs(venv string) string {
+	name := "pipreqs"
+	if runtime.GOOS == "windows" {
+		name += ".exe"
+	}
+	bin := filepath.Join(venv, venvBinDir(), name)
+	if info, err := os.Stat(bin); err == nil && !info.IsDir() {
+		return bin
+	}
+	return ""
+}
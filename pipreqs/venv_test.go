@@ -0,0 +1,62 @@
+package pipreqs
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestFindVenv(t *testing.T) {
+	dir := t.TempDir()
+	if got := findVenv(dir); got != "" {
+		t.Errorf("findVenv(empty) = %q", got)
+	}
+	// a plain directory called venv is not a virtualenv
+	makeTree(t, dir, "venv/lib.py")
+	if got := findVenv(dir); got != "" {
+		t.Errorf("findVenv without pyvenv.cfg = %q", got)
+	}
+	makeTree(t, dir, "venv/pyvenv.cfg")
+	if got, want := findVenv(dir), filepath.Join(dir, "venv"); got != want {
+		t.Errorf("findVenv = %q, want %q", got, want)
+	}
+	makeTree(t, dir, ".venv/pyvenv.cfg")
+	if got, want := findVenv(dir), filepath.Join(dir, ".venv"); got != want {
+		t.Errorf("findVenv = %q, want %q (.venv preferred)", got, want)
+	}
+}
+
+func TestUpdateRequirementsPreferVenv(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses a shell script as pipreqs")
+	}
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh not available")
+	}
+	dir := t.TempDir()
+	venv := filepath.Join(dir, ".venv")
+	makeTree(t, dir, ".venv/pyvenv.cfg", ".venv/bin/pipreqs")
+	script := "#!/bin/sh\necho \"# $VIRTUAL_ENV\" > requirements.txt\necho \"# ${PATH%%:*}\" >> requirements.txt\n"
+	bin := filepath.Join(venv, "bin", "pipreqs")
+	if err := os.WriteFile(bin, []byte(script), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chmod(bin, 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	res := UpdateRequirements(context.Background(), dir, Options{PreferVenv: true})
+	if res.Err != nil {
+		t.Fatal(res.Err)
+	}
+	got, err := os.ReadFile(filepath.Join(dir, "requirements.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "# " + venv + "\n# " + filepath.Join(venv, "bin") + "\n"; string(got) != want {
+		t.Errorf("requirements.txt = %q, want %q", got, want)
+	}
+}
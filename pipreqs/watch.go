@@ -0,0 +1,193 @@
+package pipreqs
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"log/slog"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// Defaults for WatchOptions.
+const (
+	DefaultDebounce = 500 * time.Millisecond
+	DefaultRescan   = 10 * time.Second
+)
+
+// WatchOptions configures Watch.
+type WatchOptions struct {
+	Debounce time.Duration // quiet period after the last change before regenerating; DefaultDebounce if 0
+	Rescan   time.Duration // how often to rediscover requirements files; DefaultRescan if 0
+
+	// OnRun, if set, is called with the Summary of the initial run and of
+	// every rerun.
+	OnRun func(Summary)
+}
+
+// Watch runs like Run, then watches the Python sources below each target
+// and regenerates a target whenever they change, once no further change
+// has arrived for the debounce period. Requirements files that appear
+// later are found by rediscovering every Rescan period and processed right
+// away. Reruns use Config.Concurrency like the initial run. Watch returns
+// nil when ctx is cancelled; the error is for problems that stop it before
+// the initial run.
+func Watch(ctx context.Context, cfg Config, wo WatchOptions) error {
+	logger := cfg.Logger
+	if logger == nil {
+		logger = slog.New(slog.DiscardHandler)
+	}
+	debounce := wo.Debounce
+	if debounce <= 0 {
+		debounce = DefaultDebounce
+	}
+	rescan := wo.Rescan
+	if rescan <= 0 {
+		rescan = DefaultRescan
+	}
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	defer watcher.Close()
+
+	targets, err := collectTargets(cfg, logger)
+	if err != nil {
+		return err
+	}
+	known := map[Target]bool{}
+	for _, t := range targets {
+		known[t] = true
+		watchTree(watcher, t.Dir, logger)
+	}
+	run := func(targets []Target) {
+		s := runTargets(ctx, cfg, targets, time.Now())
+		if wo.OnRun != nil {
+			wo.OnRun(s)
+		}
+	}
+	run(targets)
+	logger.Info("watching for changes", "targets", len(targets))
+
+	pending := map[Target]bool{}
+	var timer *time.Timer
+	var fire <-chan time.Time
+	schedule := func() {
+		if timer == nil {
+			timer = time.NewTimer(debounce)
+		} else {
+			timer.Reset(debounce)
+		}
+		fire = timer.C
+	}
+	ticker := time.NewTicker(rescan)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case ev, ok := <-watcher.Events:
+			if !ok {
+				return nil
+			}
+			if ev.Has(fsnotify.Create) {
+				// a new package directory needs its own watch
+				watchTree(watcher, ev.Name, logger)
+			}
+			if !isWatchedSource(ev.Name, cfg.Update.ScanNotebooks) {
+				continue
+			}
+			for _, t := range targets {
+				if within(t.Dir, ev.Name) && !pending[t] {
+					logger.Debug("sources changed", "path", t.Path(), "file", ev.Name)
+					pending[t] = true
+				}
+			}
+			if len(pending) > 0 {
+				schedule()
+			}
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return nil
+			}
+			logger.Warn("watch error", "err", err)
+		case <-ticker.C:
+			found, err := collectTargets(cfg, slog.New(slog.DiscardHandler))
+			if err != nil {
+				logger.Warn("rescan failed", "err", err)
+				continue
+			}
+			for _, t := range found {
+				if known[t] {
+					continue
+				}
+				logger.Info("new requirements file", "path", t.Path())
+				known[t] = true
+				targets = append(targets, t)
+				watchTree(watcher, t.Dir, logger)
+				pending[t] = true
+			}
+			if len(pending) > 0 && fire == nil {
+				schedule()
+			}
+		case <-fire:
+			fire = nil
+			batch := make([]Target, 0, len(pending))
+			for t := range pending {
+				batch = append(batch, t)
+			}
+			clear(pending)
+			SortTargets(batch)
+			run(batch)
+		}
+	}
+}
+
+// watchTree adds dir and every directory below it that pipreqs would scan
+// to watcher. Directories that cannot be watched are logged and left out;
+// ones that vanished in the meantime are not worth a warning.
+func watchTree(watcher *fsnotify.Watcher, dir string, logger *slog.Logger) {
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() {
+			return nil
+		}
+		if path != dir && slices.Contains(pythonIgnoreDirs, d.Name()) {
+			return fs.SkipDir
+		}
+		return watcher.Add(path)
+	})
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		logger.Warn("cannot watch directory", "path", dir, "err", err)
+	}
+}
+
+// isWatchedSource reports whether a change to path can change pipreqs'
+// output: a .py file, or a notebook when notebooks are scanned.
+func isWatchedSource(path string, notebooks bool) bool {
+	ext := filepath.Ext(path)
+	return strings.EqualFold(ext, ".py") || (notebooks && strings.EqualFold(ext, ".ipynb"))
+}
+
+// within reports whether path is dir or lies below it, outside the
+// directories pipreqs ignores.
+func within(dir, path string) bool {
+	rel, err := filepath.Rel(dir, path)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return false
+	}
+	for _, part := range strings.Split(filepath.Dir(rel), string(filepath.Separator)) {
+		if slices.Contains(pythonIgnoreDirs, part) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,59 @@
+package pipreqs
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestWatch(t *testing.T) {
+	root := t.TempDir()
+	makeTree(t, root, "a/requirements.txt", "a/app.py", "b/requirements.txt", "b/app.py")
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	runs := make(chan Summary)
+	done := make(chan error)
+	go func() {
+		done <- Watch(ctx, Config{
+			Root:        root,
+			Discover:    DiscoverOptions{MaxDepth: 1},
+			Update:      Options{Runner: &fakeRunner{content: "requests==2.31.0\n"}},
+			Concurrency: 1,
+		}, WatchOptions{
+			Debounce: 20 * time.Millisecond,
+			Rescan:   50 * time.Millisecond,
+			OnRun:    func(s Summary) { runs <- s },
+		})
+	}()
+	next := func() Summary {
+		t.Helper()
+		select {
+		case s := <-runs:
+			return s
+		case <-time.After(5 * time.Second):
+			t.Fatal("no run within 5s")
+		}
+		return Summary{}
+	}
+
+	if s := next(); s.Processed != 2 {
+		t.Fatalf("initial run processed %d, want 2", s.Processed)
+	}
+
+	writeFile(t, filepath.Join(root, "b", "app.py"), "import flask\n")
+	writeFile(t, filepath.Join(root, "b", "notes.txt"), "not a source\n")
+	if s := next(); s.Processed != 1 || s.Results[0].Dir != filepath.Join(root, "b") {
+		t.Errorf("rerun after a change = %+v, want only b", s)
+	}
+
+	makeTree(t, root, "c/app.py", "c/requirements.txt")
+	if s := next(); s.Processed != 1 || s.Results[0].Dir != filepath.Join(root, "c") {
+		t.Errorf("rerun after rescan = %+v, want only the new c", s)
+	}
+
+	cancel()
+	if err := <-done; err != nil {
+		t.Errorf("Watch = %v, want nil after cancellation", err)
+	}
+}